@@ -0,0 +1,7 @@
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+var _ = proto.Marshal
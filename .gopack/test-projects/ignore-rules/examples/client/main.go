@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func main() {
+	memcache.New("localhost:11211")
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"corp.internal/metrics"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func main() {
+	metrics.Start()
+	http.Handle("/", mux.NewRouter())
+	http.ListenAndServe(":2345", nil)
+}
@@ -0,0 +1,7 @@
+package fixture
+
+import (
+	"github.com/d2fn/fixture"
+)
+
+var _ = fixture.Value
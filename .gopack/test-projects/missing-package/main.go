@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/foo/bar/gone"
+	"github.com/foo/bar/here"
+)
+
+func main() {
+	here.Run(gone.Config())
+}
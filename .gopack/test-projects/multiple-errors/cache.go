@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/gorilla/mux"
+)
+
+var client = memcache.New("localhost:11211")
+
+var router = mux.NewRouter()
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func main() {
+	http.Handle("/", mux.NewRouter())
+	http.ListenAndServe(":2345", nil)
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/foo/bar/baz/qux"
+)
+
+func main() {
+	qux.Run()
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/foo/bar/baz/qux"
+	"github.com/foo/other/pkg/lib/util"
+)
+
+func main() {
+	qux.Run(util.Config())
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/foo/barista"
+)
+
+func main() {
+	barista.Run()
+}
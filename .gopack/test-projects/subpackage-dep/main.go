@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/foo/bar"
+	"github.com/foo/bar/baz"
+)
+
+func main() {
+	bar.Run(baz.Config())
+}
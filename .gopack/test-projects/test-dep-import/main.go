@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+)
+
+var _ = assert.Equal
+
+func main() {
+	http.Handle("/", mux.NewRouter())
+	http.ListenAndServe(":2345", nil)
+}
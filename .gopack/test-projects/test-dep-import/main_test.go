@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMain(t *testing.T) {
+	assert.True(t, true)
+}
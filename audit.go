@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Advisory database used by gp audit when --db isn't given.
+var defaultAdvisoryDb = filepath.Join(os.Getenv("HOME"), ".gopack", "osv")
+
+// A vulnerability advisory in the OSV format.
+type Advisory struct {
+	ID       string            `json:"id"`
+	Aliases  []string          `json:"aliases"`
+	Summary  string            `json:"summary"`
+	Affected []AffectedPackage `json:"affected"`
+}
+
+type AffectedPackage struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []AffectedRange `json:"ranges"`
+	// versions affected, in addition to the ranges
+	Versions []string `json:"versions"`
+}
+
+// Events introducing and fixing the vulnerability, in SEMVER, ECOSYSTEM or GIT versions.
+type AffectedRange struct {
+	Type   string       `json:"type"`
+	Events []RangeEvent `json:"events"`
+}
+
+type RangeEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// The checkout of a dependency to audit: its tag if it's pinned to one or if one
+// points at its revision, and its revision.
+type AuditTarget struct {
+	Dep      *Dep
+	Tag      string
+	Revision string
+}
+
+// A dependency the advisories naming it can't be checked against, since they
+// only give versions and no version tag points at its revision.
+type UnauditedTarget struct {
+	Target     *AuditTarget
+	Advisories []string
+}
+
+// Load the advisories of an OSV database, either a JSON file with one advisory
+// or a list of them, or a directory of such files.
+func LoadAdvisories(path string) ([]*Advisory, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	advisories := []*Advisory{}
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var list []*Advisory
+		if err := json.Unmarshal(dat, &list); err != nil {
+			var advisory Advisory
+			if err := json.Unmarshal(dat, &advisory); err != nil {
+				return nil, fmt.Errorf("%s - %s", file, err)
+			}
+			list = []*Advisory{&advisory}
+		}
+		advisories = append(advisories, list...)
+	}
+	return advisories, nil
+}
+
+// Report the dependencies affected by the advisories. Tags are matched against the
+// versions of the advisories and revisions against their git ranges, using isAncestor
+// to tell whether a commit is an ancestor of the revision checked out.
+func Audit(advisories []*Advisory, targets []*AuditTarget, isAncestor func(dep *Dep, commit string) bool) []*ProjectError {
+	sorted := make([]*AuditTarget, len(targets))
+	copy(sorted, targets)
+	sort.Sort(targetsByImport(sorted))
+
+	errors := []*ProjectError{}
+	for _, target := range sorted {
+		for _, advisory := range advisories {
+			for _, affected := range advisory.Affected {
+				if !providesModule(target.Dep, affected.Package.Name) {
+					continue
+				}
+				ancestor := func(commit string) bool { return isAncestor(target.Dep, commit) }
+				if affected.affects(target, ancestor) {
+					errors = append(errors, VulnerableDependencyError(target, advisory, &affected))
+				}
+			}
+		}
+	}
+	return errors
+}
+
+// The targets that can't be audited against some of the advisories naming them.
+func Unaudited(advisories []*Advisory, targets []*AuditTarget) []*UnauditedTarget {
+	sorted := make([]*AuditTarget, len(targets))
+	copy(sorted, targets)
+	sort.Sort(targetsByImport(sorted))
+
+	unaudited := []*UnauditedTarget{}
+	for _, target := range sorted {
+		ids := []string{}
+		for _, advisory := range advisories {
+			for _, affected := range advisory.Affected {
+				if providesModule(target.Dep, affected.Package.Name) && !affected.auditable(target) {
+					ids = append(ids, advisory.ID)
+					break
+				}
+			}
+		}
+		if len(ids) > 0 {
+			unaudited = append(unaudited, &UnauditedTarget{target, ids})
+		}
+	}
+	return unaudited
+}
+
+func providesModule(dep *Dep, name string) bool {
+	return name != "" && (name == RepoRoot(dep.Import) || dep.Import == name || strings.HasPrefix(dep.Import, name+"/"))
+}
+
+func (a *AffectedPackage) affects(target *AuditTarget, isAncestor func(commit string) bool) bool {
+	version := strings.TrimPrefix(target.Tag, "v")
+	for _, v := range a.Versions {
+		if version != "" && strings.TrimPrefix(v, "v") == version {
+			return true
+		}
+	}
+
+	for _, r := range a.Ranges {
+		switch r.Type {
+		case "SEMVER", "ECOSYSTEM":
+			if isSemver(version) && r.affectsVersion(version) {
+				return true
+			}
+		case "GIT":
+			if target.Revision != "" && r.affectsRevision(target.Revision, isAncestor) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Whether the affected versions and ranges can be checked against the target:
+// versions need a version tag, and git ranges a revision.
+func (a *AffectedPackage) auditable(target *AuditTarget) bool {
+	if isSemver(target.Tag) {
+		return true
+	}
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" && target.Revision != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the version is between an introduced and a fixed version of the range.
+func (r *AffectedRange) affectsVersion(version string) bool {
+	events := make([]RangeEvent, len(r.Events))
+	copy(events, r.Events)
+	sort.Stable(eventsByVersion(events))
+
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || compareSemver(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if compareSemver(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if compareSemver(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// Whether an introducing commit is an ancestor of the revision and no fixing commit is.
+func (r *AffectedRange) affectsRevision(revision string, isAncestor func(commit string) bool) bool {
+	introduced, fixed := false, false
+	for _, e := range r.Events {
+		if e.Introduced == "0" || (e.Introduced != "" && (e.Introduced == revision || isAncestor(e.Introduced))) {
+			introduced = true
+		}
+		if e.Fixed != "" && (e.Fixed == revision || isAncestor(e.Fixed)) {
+			fixed = true
+		}
+	}
+	return introduced && !fixed
+}
+
+func (e RangeEvent) version() string {
+	for _, v := range []string{e.Introduced, e.Fixed, e.LastAffected} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// The ranges of the affected package in a readable form, like ">= 0, < 1.6.0".
+func (a *AffectedPackage) describeRanges() []string {
+	ranges := []string{}
+	for _, r := range a.Ranges {
+		bounds := []string{}
+		for _, e := range r.Events {
+			switch {
+			case e.Introduced != "":
+				bounds = append(bounds, ">= "+e.Introduced)
+			case e.Fixed != "":
+				bounds = append(bounds, "< "+e.Fixed)
+			case e.LastAffected != "":
+				bounds = append(bounds, "<= "+e.LastAffected)
+			}
+		}
+		ranges = append(ranges, fmt.Sprintf("%s %s", strings.ToLower(r.Type), strings.Join(bounds, ", ")))
+	}
+	return ranges
+}
+
+func (a *AffectedPackage) fixedVersions() []string {
+	fixed := []string{}
+	for _, r := range a.Ranges {
+		for _, e := range r.Events {
+			if e.Fixed != "" {
+				fixed = append(fixed, e.Fixed)
+			}
+		}
+	}
+	return fixed
+}
+
+func isSemver(version string) bool {
+	_, ok := parseSemver(version)
+	return ok
+}
+
+// The major, minor and patch numbers of the version and its prerelease, if any.
+func parseSemver(version string) ([]string, bool) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+	prerelease := ""
+	if i := strings.Index(version, "-"); i >= 0 {
+		version, prerelease = version[:i], version[i+1:]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return nil, false
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return nil, false
+		}
+	}
+	return append(parts, prerelease), true
+}
+
+// Compare two semantic versions, with or without the v prefix.
+// Versions that can't be parsed sort first.
+func compareSemver(a, b string) int {
+	pa, okA := parseSemver(a)
+	pb, okB := parseSemver(b)
+	if !okA || !okB {
+		return compareInts(boolInt(okA), boolInt(okB))
+	}
+	for i := 0; i < 3; i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if c := compareInts(na, nb); c != 0 {
+			return c
+		}
+	}
+
+	// a prerelease comes before its release
+	switch {
+	case pa[3] == pb[3]:
+		return 0
+	case pa[3] == "":
+		return 1
+	case pb[3] == "":
+		return -1
+	}
+	return comparePrerelease(pa[3], pb[3])
+}
+
+func comparePrerelease(a, b string) int {
+	ia, ib := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		na, errA := strconv.Atoi(ia[i])
+		nb, errB := strconv.Atoi(ib[i])
+		var c int
+		if errA == nil && errB == nil {
+			c = compareInts(na, nb)
+		} else {
+			c = strings.Compare(ia[i], ib[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(ia), len(ib))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type eventsByVersion []RangeEvent
+
+func (e eventsByVersion) Len() int      { return len(e) }
+func (e eventsByVersion) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e eventsByVersion) Less(i, j int) bool {
+	return compareSemver(e[i].version(), e[j].version()) < 0
+}
+
+type targetsByImport []*AuditTarget
+
+func (t targetsByImport) Len() int           { return len(t) }
+func (t targetsByImport) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t targetsByImport) Less(i, j int) bool { return t[i].Dep.Import < t[j].Dep.Import }
+
+// Whether commit is an ancestor of the revision checked out, only git can tell.
+func scmIsAncestor(dep *Dep, commit string) bool {
+	if scm, err := dep.Scm(); err != nil || scm != (Git{}) {
+		return false
+	}
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", commit, "HEAD")
+	cmd.Dir = dep.Src()
+	return cmd.Run() == nil
+}
+
+// The highest version tag pointing at the revision checked out, only git can tell.
+func scmVersionTag(dep *Dep) string {
+	if scm, err := dep.Scm(); err != nil || scm != (Git{}) {
+		return ""
+	}
+	cmd := exec.Command("git", "tag", "--points-at", "HEAD")
+	cmd.Dir = dep.Src()
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return highestVersion(strings.Fields(string(out)))
+}
+
+func highestVersion(tags []string) string {
+	highest := ""
+	for _, tag := range tags {
+		if isSemver(tag) && (highest == "" || compareSemver(tag, highest) > 0) {
+			highest = tag
+		}
+	}
+	return highest
+}
+
+// gp audit [--db path]
+func auditDependencies(config *Config, p *ProjectStats, args []string) {
+	db := flagValue(args, "--db", defaultAdvisoryDb)
+	advisories, err := LoadAdvisories(db)
+	if err != nil {
+		failf("can't load the advisory database: %s\n", err)
+	}
+
+	targets := []*AuditTarget{}
+	// the audit reads the checkouts as they are, it doesn't access the network
+	for _, dep := range vendoredDependencies(config) {
+		target := &AuditTarget{Dep: dep}
+		if dep.CheckoutFlag == TagFlag {
+			target.Tag = dep.CheckoutSpec
+		} else {
+			// advisories mostly give versions, which commits and branches may have been tagged with
+			target.Tag = scmVersionTag(dep)
+		}
+		target.Revision, _ = scmRevision(dep)
+		targets = append(targets, target)
+	}
+
+	unaudited := Unaudited(advisories, targets)
+	for _, u := range unaudited {
+		fmtcolor(Yellow, "warning: %s at %s can't be audited against %s, no version tag points at its revision\n",
+			u.Target.Dep.Import, u.Target.Dep.Revision(), strings.Join(u.Advisories, ", "))
+	}
+
+	vulnerabilities := Audit(advisories, targets, scmIsAncestor)
+	if len(vulnerabilities) == 0 {
+		fmtcolor(Green, "no known vulnerabilities in %d dependencies\n", len(targets)-len(unaudited))
+		return
+	}
+
+	// the audit was asked for, so findings are reported even when validation is
+	// skipped or turned off, and the severity only decides whether it fails
+	if config.Severities.Of(VulnerableDep) == SeverityError {
+		failWith(vulnerabilities)
+	}
+	warnWith(vulnerabilities)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ginAdvisory = `{
+  "id": "GO-2020-0001",
+  "aliases": ["CVE-2020-36567"],
+  "summary": "Arbitrary log line injection in github.com/gin-gonic/gin",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/gin-gonic/gin"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.6.0"}]}]
+  }]
+}`
+
+const yamlAdvisories = `[{
+  "id": "GHSA-r88r-gmrh-7j83",
+  "summary": "Excessive resource consumption in gopkg.in/yaml.v2",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "gopkg.in/yaml.v2"},
+    "ranges": [{"type": "GIT", "repo": "https://github.com/go-yaml/yaml", "events": [{"introduced": "0"}, {"fixed": "bb4e33b"}]}],
+    "versions": ["v2.2.7"]
+  }]
+}]`
+
+func TestLoadAdvisories(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "osv")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "GO-2020-0001.json"), []byte(ginAdvisory), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "yaml.json"), []byte(yamlAdvisories), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not an advisory"), 0644)
+
+	advisories, err := LoadAdvisories(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advisories) != 2 || advisories[0].ID != "GO-2020-0001" || advisories[1].ID != "GHSA-r88r-gmrh-7j83" {
+		t.Fatalf("Expected the advisories of both files, but was %v", advisories)
+	}
+	if events := advisories[0].Affected[0].Ranges[0].Events; len(events) != 2 || events[1].Fixed != "1.6.0" {
+		t.Errorf("Expected the range events to be loaded, but was %v", events)
+	}
+
+	advisories, err = LoadAdvisories(filepath.Join(dir, "yaml.json"))
+	if err != nil || len(advisories) != 1 {
+		t.Errorf("Expected the advisory of the file, but was %v, %v", advisories, err)
+	}
+
+	if _, err := LoadAdvisories(filepath.Join(dir, "README")); err == nil {
+		t.Error("Expected an error loading a file that isn't JSON")
+	}
+}
+
+func TestAuditTags(t *testing.T) {
+	advisories, _ := LoadAdvisories(writeAdvisory(ginAdvisory))
+	for tag, affected := range map[string]bool{
+		"v1.4.0":        true,
+		"v1.6.0-rc.1":   true,
+		"v1.6.0":        false,
+		"v1.7.2":        false,
+		"release-1.4.x": false,
+	} {
+		dep := NewDependency("github.com/gin-gonic/gin/binding")
+		errors := Audit(advisories, []*AuditTarget{{Dep: dep, Tag: tag}}, neverAncestor)
+		if affected != (len(errors) == 1) {
+			t.Errorf("Expected %s affected to be %v, but was %v", tag, affected, errors)
+		}
+	}
+
+	dep := NewDependency("github.com/gin-gonic/gin")
+	errors := Audit(advisories, []*AuditTarget{{Dep: dep, Tag: "v1.5.0"}}, neverAncestor)
+	if len(errors) != 1 {
+		t.Fatalf("Expected one finding, but was %v", errors)
+	}
+	e := errors[0]
+	if e.Kind != VulnerableDep || e.Path != "github.com/gin-gonic/gin" {
+		t.Errorf("Expected a vulnerable-dep error on gin, but was %s %s", e.Kind, e.Path)
+	}
+	for _, expected := range []string{"at v1.5.0", "GO-2020-0001 (CVE-2020-36567)", "affected semver >= 0, < 1.6.0", "fixed in 1.6.0"} {
+		if !strings.Contains(e.Message, expected) {
+			t.Errorf("Expected the message to contain %q, but was %s", expected, e.Message)
+		}
+	}
+
+	other := NewDependency("github.com/gin-contrib/cors")
+	if errors := Audit(advisories, []*AuditTarget{{Dep: other, Tag: "v1.0.0"}}, neverAncestor); len(errors) != 0 {
+		t.Errorf("Expected other modules not to match, but was %v", errors)
+	}
+}
+
+func TestAuditRevisions(t *testing.T) {
+	advisories, _ := LoadAdvisories(writeAdvisory(yamlAdvisories))
+	dep := NewDependency("gopkg.in/yaml.v2")
+
+	fixedAncestor := func(d *Dep, commit string) bool { return commit == "bb4e33b" }
+	if errors := Audit(advisories, []*AuditTarget{{Dep: dep, Revision: "1f64d61"}}, fixedAncestor); len(errors) != 0 {
+		t.Errorf("Expected a revision after the fix not to be affected, but was %v", errors)
+	}
+	if errors := Audit(advisories, []*AuditTarget{{Dep: dep, Revision: "bb4e33b"}}, neverAncestor); len(errors) != 0 {
+		t.Errorf("Expected the fixing revision not to be affected, but was %v", errors)
+	}
+
+	errors := Audit(advisories, []*AuditTarget{{Dep: dep, Revision: "53403b5"}}, neverAncestor)
+	if len(errors) != 1 || !strings.Contains(errors[0].Message, "at 53403b5") || !strings.Contains(errors[0].Message, "fixed in bb4e33b") {
+		t.Errorf("Expected a revision before the fix to be affected, but was %v", errors)
+	}
+
+	errors = Audit(advisories, []*AuditTarget{{Dep: dep, Tag: "v2.2.7"}}, fixedAncestor)
+	if len(errors) != 1 || !strings.Contains(errors[0].Message, "affected versions v2.2.7") {
+		t.Errorf("Expected an affected version to match, but was %v", errors)
+	}
+}
+
+func TestUnaudited(t *testing.T) {
+	advisories, _ := LoadAdvisories(writeAdvisory(ginAdvisory))
+	yaml, _ := LoadAdvisories(writeAdvisory(yamlAdvisories))
+	advisories = append(advisories, yaml...)
+
+	gin := NewDependency("github.com/gin-gonic/gin")
+	gin.checkout(BranchFlag, "master")
+	targets := []*AuditTarget{
+		{Dep: gin, Revision: "a1b2c3d"},
+		{Dep: NewDependency("github.com/gin-gonic/gin/binding"), Tag: "v1.7.2", Revision: "e4f5a6b"},
+		{Dep: NewDependency("gopkg.in/yaml.v2"), Revision: "53403b5"},
+		{Dep: NewDependency("github.com/gin-contrib/cors"), Revision: "c7d8e9f"},
+	}
+
+	unaudited := Unaudited(advisories, targets)
+	if len(unaudited) != 1 || unaudited[0].Target.Dep != gin || strings.Join(unaudited[0].Advisories, " ") != "GO-2020-0001" {
+		t.Errorf("Expected only the branch of gin to be unaudited, but was %v", unaudited)
+	}
+}
+
+func TestHighestVersion(t *testing.T) {
+	if v := highestVersion([]string{"latest", "v1.2.0", "v1.10.0", "v1.9.3", "release-1.11"}); v != "v1.10.0" {
+		t.Errorf("Expected v1.10.0 to be the highest version tag, but was %q", v)
+	}
+	if v := highestVersion([]string{"latest"}); v != "" {
+		t.Errorf("Expected no version tag, but was %q", v)
+	}
+}
+
+func TestVendoredDependencies(t *testing.T) {
+	config := setupTestConfig(`
+[deps.gin]
+  import = "github.com/gin-gonic/gin"
+  branch = "master"
+`)
+	createVendorConfig("github.com/gin-gonic/gin", `
+[deps.yaml]
+  import = "gopkg.in/yaml.v2"
+  tag = "v2.2.7"
+`)
+	createPath(NewDependency("gopkg.in/yaml.v2").Src())
+
+	deps := []string{}
+	for _, dep := range vendoredDependencies(config) {
+		deps = append(deps, dep.Import)
+	}
+	if strings.Join(deps, " ") != "github.com/gin-gonic/gin gopkg.in/yaml.v2" {
+		t.Errorf("Expected the checkouts and the dependencies of their configs, but were %v", deps)
+	}
+}
+
+func TestCompareSemver(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2.3", "v1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0+build", "1.0.0", 0},
+	} {
+		if actual := compareSemver(c.a, c.b); actual != c.expected {
+			t.Errorf("Expected %s compared to %s to be %d, but was %d", c.a, c.b, c.expected, actual)
+		}
+	}
+}
+
+func neverAncestor(dep *Dep, commit string) bool {
+	return false
+}
+
+func writeAdvisory(content string) string {
+	f, _ := ioutil.TempFile("", "advisory")
+	defer f.Close()
+	f.WriteString(content)
+	return f.Name()
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Value types of the config keys.
+const (
+	stringValue = iota
+	stringListValue
+	tableValue
+	boolValue
+)
+
+var (
+	configKeys = map[string]int{
+		"repo":            stringValue,
+		"deps":            tableValue,
+		"test-deps":       tableValue,
+		IncludeProp:       stringListValue,
+		IgnoreProp:        stringListValue,
+		IgnoreImportsProp: stringListValue,
+		"validate":        tableValue,
+		PolicyProp:        tableValue,
+		KeyringProp:       stringValue,
+	}
+	policyKeys = map[string]int{
+		DenyLicensesProp:     stringListValue,
+		AllowHostsProp:       stringListValue,
+		DenyImportsProp:      stringListValue,
+		RequireImmutableProp: boolValue,
+	}
+	dependencyKeys = map[string]int{
+		ImportProp:          stringValue,
+		SourceProp:          stringValue,
+		BranchProp:          stringValue,
+		CommitProp:          stringValue,
+		TagProp:             stringValue,
+		GroupProp:           stringValue,
+		OSProp:              stringListValue,
+		ArchProp:            stringListValue,
+		KeyringProp:         stringValue,
+		VerifySignatureProp: boolValue,
+	}
+	valueTypeNames = map[int]string{
+		stringValue:     "a string",
+		stringListValue: "a list of strings",
+		tableValue:      "a table",
+		boolValue:       "true or false",
+	}
+)
+
+// A problem found checking a gopack.config, at the position of the offending key.
+type ConfigProblem struct {
+	Path    string
+	Line    int
+	Col     int
+	Message string
+}
+
+func (p *ConfigProblem) Error() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.Path, p.Line, p.Col, p.Message)
+}
+
+// The problems of a config, sorted by position.
+type ConfigProblems []*ConfigProblem
+
+func (p ConfigProblems) Len() int      { return len(p) }
+func (p ConfigProblems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p ConfigProblems) Less(i, j int) bool {
+	if p[i].Line != p[j].Line {
+		return p[i].Line < p[j].Line
+	}
+	if p[i].Col != p[j].Col {
+		return p[i].Col < p[j].Col
+	}
+	return p[i].Message < p[j].Message
+}
+
+func (p ConfigProblems) Error() string {
+	lines := []string{}
+	for _, problem := range p {
+		lines = append(lines, problem.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check the config file at path against the gopack.config schema.
+func CheckConfigFile(path string) ConfigProblems {
+	t, err := toml.LoadFile(path)
+	if err != nil {
+		return ConfigProblems{&ConfigProblem{Path: path, Message: err.Error()}}
+	}
+	return CheckConfig(t, path)
+}
+
+// Check the config file at path and the files it includes, recursively.
+func CheckConfigFiles(path string) ConfigProblems {
+	return checkConfigFiles(path, map[string]bool{})
+}
+
+func checkConfigFiles(path string, checked map[string]bool) ConfigProblems {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	if checked[key] {
+		// include cycles are reported when the config is loaded
+		return ConfigProblems{}
+	}
+	checked[key] = true
+
+	t, err := toml.LoadFile(path)
+	if err != nil {
+		return ConfigProblems{&ConfigProblem{Path: path, Message: err.Error()}}
+	}
+	problems := CheckConfig(t, path)
+	includes, err := getStrings(t, IncludeProp)
+	if err != nil {
+		// reported by CheckConfig
+		return problems
+	}
+	for _, include := range includes {
+		problems = append(problems, checkConfigFiles(filepath.Join(filepath.Dir(path), include), checked)...)
+	}
+	return problems
+}
+
+// Check a loaded config for unknown keys, values of the wrong type,
+// dependency tables without import or with more than one checkout spec,
+// and imports declared in more than one table.
+func CheckConfig(t *toml.TomlTree, path string) ConfigProblems {
+	c := &configChecker{path: path, problems: ConfigProblems{}, imports: make(map[string]string)}
+
+	for _, key := range t.Keys() {
+		valueType, known := configKeys[key]
+		if !known {
+			c.report(t, key, "unknown key %s", key)
+			continue
+		}
+		c.checkType(t, key, key, valueType)
+	}
+
+	for _, name := range sharedTables {
+		if depsTree, ok := t.Get(name).(*toml.TomlTree); ok {
+			c.checkDependencies(depsTree, name)
+		}
+	}
+
+	if validate, ok := t.Get("validate").(*toml.TomlTree); ok {
+		for _, kind := range validate.Keys() {
+			if !knownErrorKind(kind) {
+				c.report(validate, kind, "[validate] unknown validation error kind %s", kind)
+			} else if c.checkType(validate, kind, "[validate] "+kind, stringValue) {
+				severity := validate.Get(kind).(string)
+				if severity != SeverityError && severity != SeverityWarning && severity != SeverityOff {
+					c.report(validate, kind, "[validate] %s must be one of error, warning or off", kind)
+				}
+			}
+		}
+	}
+
+	if policy, ok := t.Get(PolicyProp).(*toml.TomlTree); ok {
+		c.checkPolicy(policy)
+	}
+
+	sort.Sort(c.problems)
+	return c.problems
+}
+
+func (c *configChecker) checkPolicy(policy *toml.TomlTree) {
+	for _, key := range policy.Keys() {
+		valueType, known := policyKeys[key]
+		if !known {
+			c.report(policy, key, "[policy] unknown key %s", key)
+			continue
+		}
+		if !c.checkType(policy, key, "[policy] "+key, valueType) || valueType != stringListValue {
+			continue
+		}
+		for _, value := range policy.Get(key).([]interface{}) {
+			switch {
+			case key == DenyLicensesProp && !knownLicense(value.(string)):
+				c.report(policy, key, "[policy] %s unknown license %s", key, value)
+			case key == AllowHostsProp && strings.ContainsAny(value.(string), "/@:"):
+				c.report(policy, key, "[policy] %s %s isn't a host name", key, value)
+			}
+		}
+	}
+}
+
+type configChecker struct {
+	path     string
+	problems ConfigProblems
+	// table declaring each import
+	imports map[string]string
+}
+
+func (c *configChecker) report(t *toml.TomlTree, key string, format string, args ...interface{}) {
+	pos := t.GetPosition(key)
+	c.problems = append(c.problems, &ConfigProblem{c.path, pos.Line, pos.Col, fmt.Sprintf(format, args...)})
+}
+
+// Whether the value at key has the expected type, reporting it when it doesn't.
+func (c *configChecker) checkType(t *toml.TomlTree, key, name string, valueType int) bool {
+	ok := true
+	switch value := t.Get(key).(type) {
+	case string:
+		ok = valueType == stringValue
+	case *toml.TomlTree:
+		ok = valueType == tableValue
+	case bool:
+		ok = valueType == boolValue
+	case []interface{}:
+		ok = valueType == stringListValue
+		for _, v := range value {
+			if _, isString := v.(string); !isString {
+				ok = false
+			}
+		}
+	default:
+		ok = false
+	}
+
+	if !ok {
+		c.report(t, key, "%s must be %s", name, valueTypeNames[valueType])
+	}
+	return ok
+}
+
+func (c *configChecker) checkDependencies(depsTree *toml.TomlTree, name string) {
+	keys := depsTree.Keys()
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		table := fmt.Sprintf("[%s.%s]", name, k)
+		if !c.checkType(depsTree, k, table, tableValue) {
+			continue
+		}
+		depTree := depsTree.Get(k).(*toml.TomlTree)
+
+		checkouts := []string{}
+		for _, key := range depTree.Keys() {
+			valueType, known := dependencyKeys[key]
+			if !known {
+				c.report(depTree, key, "%s unknown key %s", table, key)
+				continue
+			}
+			if !c.checkType(depTree, key, table+" "+key, valueType) {
+				continue
+			}
+			if key == BranchProp || key == CommitProp || key == TagProp {
+				checkouts = append(checkouts, key)
+			}
+			if key == GroupProp {
+				if group := depTree.Get(key).(string); group != "" && group != TestGroup {
+					c.report(depTree, key, "%s unknown group %s", table, group)
+				}
+			}
+		}
+
+		if len(checkouts) > 1 {
+			sort.Strings(checkouts)
+			c.report(depsTree, k, "%s only one of branch/commit/tag may be specified, found %s", table, strings.Join(checkouts, ", "))
+		}
+
+		importPath, ok := depTree.Get(ImportProp).(string)
+		if depTree.Get(ImportProp) == nil {
+			c.report(depsTree, k, "%s has no import", table)
+		} else if ok {
+			if other, found := c.imports[importPath]; found {
+				c.report(depTree, ImportProp, "%s import %s is already declared in %s", table, importPath, other)
+			} else {
+				c.imports[importPath] = table
+			}
+		}
+	}
+}
+
+// gp config check
+func configCommand(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		failf("usage: gp config check\n")
+	}
+	checkConfiguration("gopack.config")
+}
+
+func checkConfiguration(path string) {
+	problems := CheckConfigFiles(path)
+	if len(problems) == 0 {
+		fmtcolor(Green, "%s is valid\n", path)
+		return
+	}
+
+	fmtcolor(Red, "%s\n", problems.Error())
+	os.Exit(len(problems))
+}
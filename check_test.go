@@ -0,0 +1,112 @@
+package main
+
+import (
+	"github.com/pelletier/go-toml"
+	"path"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	tree, err := toml.Load(`repo = "github.com/d2fn/gopack"
+ignored = ["examples"]
+
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = 1.2
+
+[deps.toml]
+  import = "github.com/pelletier/go-toml"
+  branch = "master"
+  commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+
+[deps.nohost]
+  branch = "master"
+
+[test-deps.gorilla]
+  import = "github.com/gorilla/mux"
+  tagg = "1.0rc2"
+
+[validate]
+unused-dep = "warn"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"gopack.config:2:1: unknown key ignored",
+		"gopack.config:6:3: [deps.mux] tag must be a string",
+		"gopack.config:8:1: [deps.toml] only one of branch/commit/tag may be specified, found branch, commit",
+		"gopack.config:13:1: [deps.nohost] has no import",
+		"gopack.config:17:3: [test-deps.gorilla] import github.com/gorilla/mux is already declared in [deps.mux]",
+		"gopack.config:18:3: [test-deps.gorilla] unknown key tagg",
+		"gopack.config:21:1: [validate] unused-dep must be one of error, warning or off",
+	}
+
+	problems := CheckConfig(tree, "gopack.config")
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, but were:\n%s", len(expected), problems.Error())
+	}
+	for i, p := range problems {
+		if p.Error() != expected[i] {
+			t.Errorf("Expected %q, but was %q", expected[i], p.Error())
+		}
+	}
+}
+
+func TestCheckValidConfig(t *testing.T) {
+	tree, _ := toml.Load(`
+repo = "github.com/d2fn/gopack"
+include = ["../common/gopack.config"]
+
+[deps.sys]
+  import = "golang.org/x/sys/windows"
+  commit = "a3cbe84bfdd6a8cc1ff7cd7ea0dd9c9c7dbcad5f"
+  os = ["windows"]
+
+[test-deps.assert]
+  import = "github.com/stretchr/testify"
+  tag = "v1.0"
+  group = "test"
+`)
+
+	if problems := CheckConfig(tree, "gopack.config"); len(problems) != 0 {
+		t.Errorf("Expected no problems, but were:\n%s", problems.Error())
+	}
+}
+
+func TestCheckConfigFiles(t *testing.T) {
+	setupTestPwd()
+	createPath(path.Join(pwd, "common"))
+	createFixtureConfig(path.Join(pwd, "common"), `
+unknown = "value"
+`)
+	createFixtureConfig(pwd, `
+include = ["common/gopack.config"]
+`)
+
+	problems := CheckConfigFiles(path.Join(pwd, "gopack.config"))
+	if len(problems) != 1 || problems[0].Path != path.Join(pwd, "common", "gopack.config") {
+		t.Errorf("Expected the problem of the included file, but were:\n%s", problems.Error())
+	}
+}
+
+func TestDependencyConfigProblemsOnlyWarn(t *testing.T) {
+	setupTestPwd()
+	createVendorConfig("github.com/acme/lib", `
+written-for = "a newer gopack"
+
+[deps.log]
+  import = "github.com/acme/log"
+  tag = "v1.0.0"
+
+[validate]
+new-kind = "off"
+`)
+
+	config := NewDependencyConfig(NewDependency("github.com/acme/lib").Src())
+	deps := config.LoadDependencyModel(NewGraph())
+	if deps == nil || len(deps.DepList) != 1 || deps.DepList[0].Import != "github.com/acme/log" {
+		t.Errorf("Expected the dependencies of the config to be loaded despite its problems, but were %v", deps)
+	}
+}
@@ -5,40 +5,274 @@ import (
 	"crypto/md5"
 	"fmt"
 	"github.com/pelletier/go-toml"
+	"go/build"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 )
 
 type Config struct {
 	Checksum []byte
 	// Path to the configuration file.
 	Path string
+	// Project directory, linked into the vendor tree and holding the checksum.
+	// It's the working directory except for workspace members.
+	Dir string
 	// Name of your repository "github.com/d2fn/gopack" for instance.
 	Repository string
 	// Dependencies tree
 	DepsTree *toml.TomlTree
+	// Test dependencies tree, only fetched for tests.
+	TestDepsTree *toml.TomlTree
+	// Whether test dependencies are fetched with this configuration.
+	IncludeTests bool
+	// Paths and imports left out of the source analysis.
+	Ignore *Ignore
+	// Severity of each kind of validation error.
+	Severities Severities
+	// Rules the dependencies must follow.
+	Policy *Policy
+	// Paths to the configuration files included by this one.
+	Includes []string
+	// Platform the dependencies are fetched for, dependencies restricted
+	// to other platforms with os or arch are skipped.
+	GOOS   string
+	GOARCH string
+	// Environment variables interpolated in the dependency tables.
+	Variables map[string]string
+	// Keyring verifying the signatures of the dependencies without their own.
+	Keyring string
+
+	platformSpecific bool
+	// the config of a dependency rather than of the project
+	dependency bool
 }
 
 func NewConfig(dir string) *Config {
-	config := &Config{Path: fmt.Sprintf("%s/gopack.config", dir)}
+	return loadConfig(dir, false)
+}
+
+// The config of a dependency in the vendor tree, which may have been written for
+// another version of gopack and can't be fixed by the project using it.
+func NewDependencyConfig(dir string) *Config {
+	return loadConfig(dir, true)
+}
+
+func loadConfig(dir string, dependency bool) *Config {
+	config := &Config{
+		Path:       fmt.Sprintf("%s/gopack.config", dir),
+		Dir:        pwd,
+		GOOS:       build.Default.GOOS,
+		GOARCH:     build.Default.GOARCH,
+		Variables:  make(map[string]string),
+		dependency: dependency,
+	}
 
 	t, err := toml.LoadFile(config.Path)
 	if err != nil {
-		fail(err)
+		failf("%s - %s\n", config.Path, err)
 	}
+	if err := config.checkSchema(CheckConfig(t, config.Path)); err != nil {
+		failf("%s\n", err)
+	}
+
+	config.DepsTree, err = getTable(t, "deps")
+	config.check(err)
+
+	config.TestDepsTree, err = getTable(t, "test-deps")
+	config.check(err)
 
-	if deps := t.Get("deps"); deps != nil {
-		config.DepsTree = deps.(*toml.TomlTree)
+	config.Repository, err = getString(t, "repo")
+	config.check(err)
+
+	config.Keyring, err = getString(t, KeyringProp)
+	config.check(err)
+
+	config.Includes = []string{}
+	root, err := filepath.Abs(config.Path)
+	if err != nil {
+		fail(err)
+	}
+	included, err := config.loadIncludes(t, dir, []string{root})
+	if err != nil {
+		failf("%s\n", err)
 	}
+	config.DepsTree = included.mergeInto(config.DepsTree, "deps")
+	config.TestDepsTree = included.mergeInto(config.TestDepsTree, "test-deps")
+
+	config.check(config.loadDependencyTables(config.DepsTree, "deps"))
+	config.check(config.loadDependencyTables(config.TestDepsTree, "test-deps"))
 
-	if repo := t.Get("repo"); repo != nil {
-		config.Repository = repo.(string)
+	config.Ignore = &Ignore{}
+	config.Ignore.Paths, err = getStrings(t, IgnoreProp)
+	config.check(err)
+	config.Ignore.Imports, err = getStrings(t, IgnoreImportsProp)
+	config.check(err)
+
+	config.Policy, err = loadPolicy(t)
+	config.check(err)
+
+	// effectively unused dependencies are still imported, so they only warn by default
+	config.Severities = Severities{UnreachableDep: SeverityWarning}
+	validateTree, err := getTable(t, "validate")
+	config.check(err)
+	if validateTree != nil {
+		for _, kind := range validateTree.Keys() {
+			severity, err := getString(validateTree, kind)
+			config.check(err)
+			valid := severity == SeverityError || severity == SeverityWarning || severity == SeverityOff
+			if dependency && (!knownErrorKind(kind) || !valid) {
+				// already reported by checkSchema
+				continue
+			}
+			if !knownErrorKind(kind) {
+				failf("%s - unknown validation error kind %s\n", config.Path, kind)
+			}
+			if !valid {
+				failf("%s - %s must be one of error, warning or off\n", config.Path, kind)
+			}
+			config.Severities[kind] = severity
+		}
 	}
 
 	return config
 }
 
+// The schema problems of the config or of a file it includes, only reported
+// as warnings for the configs of dependencies.
+func (c *Config) checkSchema(problems ConfigProblems) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	if !c.dependency {
+		return problems
+	}
+	for _, problem := range problems {
+		fmtcolor(Yellow, "warning: %s\n", problem.Error())
+	}
+	return nil
+}
+
+func (c *Config) check(err error) {
+	if err != nil {
+		failf("%s - %s\n", c.Path, err)
+	}
+}
+
+// Interpolate the environment variables in the import and source of each dependency table.
+// The tables come from configs that passed CheckConfig.
+func (c *Config) loadDependencyTables(depsTree *toml.TomlTree, name string) error {
+	if depsTree == nil {
+		return nil
+	}
+
+	for _, k := range depsTree.Keys() {
+		depTree := depsTree.Get(k).(*toml.TomlTree)
+
+		for _, key := range []string{ImportProp, SourceProp} {
+			value, err := getString(depTree, key)
+			if err == nil {
+				value, err = c.interpolate(value)
+			}
+			if err != nil {
+				pos := depTree.GetPosition(key)
+				return fmt.Errorf("%d:%d: [%s.%s] %s %s", pos.Line, pos.Col, name, k, key, err)
+			}
+			if value != "" {
+				depTree.Set(key, value)
+			}
+		}
+
+		if depTree.Get(OSProp) != nil || depTree.Get(ArchProp) != nil {
+			c.platformSpecific = true
+		}
+	}
+
+	return nil
+}
+
+var variableRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Replace the ${NAME} references in value with the environment variables,
+// recording their values so that changing them changes the checksum.
+func (c *Config) interpolate(value string) (string, error) {
+	var err error
+	interpolated := variableRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := variableRef.FindStringSubmatch(ref)[1]
+		v, found := os.LookupEnv(name)
+		if !found {
+			if err == nil {
+				err = fmt.Errorf("references undefined variable ${%s}", name)
+			}
+			return ref
+		}
+		c.Variables[name] = v
+		return v
+	})
+	return interpolated, err
+}
+
+// The string at key, empty when it isn't set.
+func getString(t *toml.TomlTree, key string) (string, error) {
+	value := t.Get(key)
+	if value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+	return s, nil
+}
+
+func getBool(t *toml.TomlTree, key string) (bool, error) {
+	value := t.Get(key)
+	if value == nil {
+		return false, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return b, nil
+}
+
+// The list of strings at key, empty when it isn't set.
+func getStrings(t *toml.TomlTree, key string) ([]string, error) {
+	values := []string{}
+	value := t.Get(key)
+	if value == nil {
+		return values, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+	for _, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// The table at key, nil when it isn't set.
+func getTable(t *toml.TomlTree, key string) (*toml.TomlTree, error) {
+	value := t.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+	table, ok := value.(*toml.TomlTree)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a table", key)
+	}
+	return table, nil
+}
+
 func (c *Config) InitRepo(importGraph *Graph) {
 	if c.Repository != "" {
 		src := fmt.Sprintf("%s/%s/src", pwd, VendorDir)
@@ -49,7 +283,7 @@ func (c *Config) InitRepo(importGraph *Graph) {
 		os.MkdirAll(base, 0755)
 
 		repo := fmt.Sprintf("%s/%s", src, c.Repository)
-		err := os.Symlink(pwd, repo)
+		err := os.Symlink(c.Dir, repo)
 		if err != nil && !os.IsExist(err) {
 			fail(err)
 		}
@@ -65,7 +299,7 @@ func (c *Config) modifiedChecksum() bool {
 }
 
 func (c *Config) WriteChecksum() {
-	os.MkdirAll(filepath.Join(pwd, GopackDir), 0755)
+	os.MkdirAll(filepath.Join(c.Dir, GopackDir), 0755)
 	err := ioutil.WriteFile(c.checksumPath(), c.checksum(), 0644)
 
 	if err != nil {
@@ -73,64 +307,131 @@ func (c *Config) WriteChecksum() {
 	}
 }
 
+// Directory of the configuration file, paths in it are relative to it.
+func (c *Config) configDir() string {
+	dir, err := filepath.Abs(filepath.Dir(c.Path))
+	if err != nil {
+		fail(err)
+	}
+	return dir
+}
+
+func (c *Config) LockPath() string {
+	return filepath.Join(c.Dir, GopackLock)
+}
+
+// Test runs keep their own checksum since they fetch
+// dependencies that other commands skip.
 func (c *Config) checksumPath() string {
-	return filepath.Join(pwd, GopackChecksum)
+	if c.IncludeTests {
+		return filepath.Join(c.Dir, GopackTestChecksum)
+	}
+	return filepath.Join(c.Dir, GopackChecksum)
 }
 
+// Checksum of the configuration file and the ones it includes,
+// the environment variables they use and the platform when it matters.
 func (c *Config) checksum() []byte {
 	if c.Checksum == nil {
-		dat, err := ioutil.ReadFile(c.Path)
-		if err != nil {
-			fail(err)
-		}
-
 		h := md5.New()
-		h.Write(dat)
+		for _, path := range append([]string{c.Path}, c.Includes...) {
+			dat, err := ioutil.ReadFile(path)
+			if err != nil {
+				fail(err)
+			}
+			h.Write(dat)
+		}
+		names := []string{}
+		for name := range c.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(h, "%s=%s\n", name, c.Variables[name])
+		}
+		if c.platformSpecific {
+			fmt.Fprintf(h, "%s/%s\n", c.GOOS, c.GOARCH)
+		}
 		c.Checksum = h.Sum(nil)
 	}
 	return c.Checksum
 }
 
+// Load the dependencies when any of them needs to be fetched, nil otherwise.
 func (c *Config) LoadDependencyModel(importGraph *Graph) (deps *Dependencies) {
-	depsTree := c.DepsTree
+	deps, fetchDeps := c.DependencyModel(importGraph)
 
-	if depsTree == nil {
+	if fetchDeps == false {
+		deps = nil
+	}
+
+	return
+}
+
+// Load all the dependencies and whether any of them needs to be fetched.
+func (c *Config) DependencyModel(importGraph *Graph) (deps *Dependencies, fetchDeps bool) {
+	if c.DepsTree == nil && c.TestDepsTree == nil {
 		return
 	}
 
 	deps = new(Dependencies)
 
-	deps.Imports = make([]string, len(depsTree.Keys()))
-	deps.Keys = make([]string, len(depsTree.Keys()))
-	deps.DepList = make([]*Dep, len(depsTree.Keys()))
+	deps.Imports = []string{}
+	deps.Keys = []string{}
+	deps.DepList = []*Dep{}
 	deps.ImportGraph = importGraph
 
 	modifiedChecksum := c.modifiedChecksum()
-	fetchDeps := modifiedChecksum
+	fetchDeps = modifiedChecksum
+
+	if c.DepsTree != nil {
+		if c.addDependencies(deps, c.DepsTree, "", modifiedChecksum) {
+			fetchDeps = true
+		}
+	}
 
-	for i, k := range depsTree.Keys() {
+	if c.TestDepsTree != nil {
+		if c.addDependencies(deps, c.TestDepsTree, TestGroup, modifiedChecksum) {
+			fetchDeps = true
+		}
+	}
+
+	return
+}
+
+// Add the dependencies in the tree to the model,
+// returns whether any of them needs to be fetched.
+func (c *Config) addDependencies(deps *Dependencies, depsTree *toml.TomlTree, group string, modifiedChecksum bool) bool {
+	fetchDeps := false
+
+	for _, k := range depsTree.Keys() {
 		depTree := depsTree.Get(k).(*toml.TomlTree)
-		d := NewDependency(depTree.Get("import").(string))
+		d := NewDependency(depTree.Get(ImportProp).(string))
 
-		d.setCheckout(depTree, "branch", BranchFlag)
-		d.setCheckout(depTree, "commit", CommitFlag)
-		d.setCheckout(depTree, "tag", TagFlag)
+		d.setCheckout(depTree, BranchProp, BranchFlag)
+		d.setCheckout(depTree, CommitProp, CommitFlag)
+		d.setCheckout(depTree, TagProp, TagFlag)
+		d.setGroup(depTree, group)
+		d.setSource(depTree)
+		d.setPlatforms(depTree)
+		d.setSignature(depTree, c.Keyring, c.configDir())
 
 		d.CheckValidity()
-		if d.Fetch(modifiedChecksum) {
+		if !allPlatforms && !d.OnPlatform(c.GOOS, c.GOARCH) {
+			d.skip = true
+			d.offPlatform = true
+		} else if d.Group == TestGroup && !c.IncludeTests {
+			d.skip = true
+		} else if d.Fetch(modifiedChecksum) {
 			fetchDeps = true
 		}
 
-		deps.Keys[i] = k
-		deps.Imports[i] = d.Import
-		deps.DepList[i] = d
+		deps.Keys = append(deps.Keys, k)
+		deps.Imports = append(deps.Imports, d.Import)
+		deps.DepList = append(deps.DepList, d)
 
 		deps.ImportGraph.Insert(d)
 	}
 
-	if fetchDeps == false {
-		deps = nil
-	}
-
-	return
+	return fetchDeps
 }
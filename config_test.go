@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/pelletier/go-toml"
 	"io/ioutil"
 	"os"
 	"path"
@@ -38,6 +39,41 @@ repo = "github.com/d2fn/gopack"
 	}
 }
 
+func TestNewConfigIgnore(t *testing.T) {
+	config := setupTestConfig(`
+ignore = ["examples/...", "testdata"]
+ignore-imports = ["appengine"]
+`)
+
+	if len(config.Ignore.Paths) != 2 || config.Ignore.Paths[0] != "examples/..." {
+		t.Errorf("Expected ignored paths to be loaded, but were %v", config.Ignore.Paths)
+	}
+
+	if len(config.Ignore.Imports) != 1 || config.Ignore.Imports[0] != "appengine" {
+		t.Errorf("Expected ignored imports to be loaded, but were %v", config.Ignore.Imports)
+	}
+}
+
+func TestNewConfigValidate(t *testing.T) {
+	config := setupTestConfig(`
+[validate]
+unused-dep = "warning"
+unmanaged-import = "off"
+`)
+
+	if config.Severities.Of(UnusedDep) != SeverityWarning {
+		t.Errorf("Expected unused dependencies to be warnings")
+	}
+
+	if config.Severities.Of(UnmanagedImport) != SeverityOff {
+		t.Errorf("Expected unmanaged imports to be off")
+	}
+
+	if config.Severities.Of(TestDepImport) != SeverityError {
+		t.Errorf("Expected errors by default")
+	}
+}
+
 func TestInitRepoWithoutRepo(t *testing.T) {
 	config := setupTestConfig(`
 [deps.testgopack]
@@ -215,3 +251,194 @@ func TestFetchWithMixedSpecsIgnoringOrder(t *testing.T) {
 		t.Errorf("Expected to fetch the branch dependencies")
 	}
 }
+
+func TestLoadTestDependencies(t *testing.T) {
+	config := setupTestConfig(`
+[deps.foo]
+  import = "github.com/calavera/foo"
+  branch = "master"
+[deps.mock]
+  import = "github.com/calavera/mock"
+  branch = "master"
+  group = "test"
+[test-deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  branch = "master"
+`)
+
+	deps := config.LoadDependencyModel(NewGraph())
+	if len(deps.DepList) != 3 {
+		t.Fatalf("Expected to load all the dependencies, but loaded %d", len(deps.DepList))
+	}
+
+	for _, dep := range deps.DepList {
+		test := dep.Import != "github.com/calavera/foo"
+		if test != (dep.Group == TestGroup) {
+			t.Errorf("Expected %s to be in the test group: %v", dep.Import, test)
+		}
+		if test != dep.skip {
+			t.Errorf("Expected %s to be skipped without tests: %v", dep.Import, test)
+		}
+	}
+
+	config.IncludeTests = true
+	deps = config.LoadDependencyModel(NewGraph())
+	for _, dep := range deps.DepList {
+		if dep.skip || !dep.fetch {
+			t.Errorf("Expected %s to be fetched with tests", dep.Import)
+		}
+	}
+}
+
+func TestWriteTestChecksum(t *testing.T) {
+	config := setupTestConfig(`
+[test-deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
+`)
+	config.WriteChecksum()
+
+	config.IncludeTests = true
+	if !config.modifiedChecksum() {
+		t.Errorf("Expected test runs to track their own checksum")
+	}
+
+	config.WriteChecksum()
+	if config.modifiedChecksum() {
+		t.Errorf("Expected test checksum to be written")
+	}
+}
+
+func TestPlatformDependencies(t *testing.T) {
+	config := setupTestConfig(`
+[deps.sys]
+  import = "golang.org/x/sys/windows"
+  branch = "master"
+  os = ["windows"]
+[deps.cpu]
+  import = "github.com/klauspost/cpuid"
+  branch = "master"
+  arch = ["amd64", "386"]
+`)
+	config.GOOS = "linux"
+	config.GOARCH = "amd64"
+
+	deps := config.LoadDependencyModel(NewGraph())
+	for _, dep := range deps.DepList {
+		offPlatform := dep.Import == "golang.org/x/sys/windows"
+		if offPlatform != dep.skip || offPlatform != dep.offPlatform {
+			t.Errorf("Expected %s to be skipped on linux/amd64: %v", dep.Import, offPlatform)
+		}
+	}
+
+	errors := deps.Validate(NewProjectStats())
+	if len(errors) != 1 || errors[0].Path != "github.com/klauspost/cpuid" {
+		t.Errorf("Expected only dependencies of this platform to be unused, but were %v", errors)
+	}
+
+	// gp vendor fetches the dependencies of every platform
+	allPlatforms = true
+	defer func() { allPlatforms = false }()
+	for _, dep := range config.LoadDependencyModel(NewGraph()).DepList {
+		if dep.skip {
+			t.Errorf("Expected %s not to be skipped for every platform", dep.Import)
+		}
+	}
+}
+
+func TestSignatureVerification(t *testing.T) {
+	config := setupTestConfig(`
+keyring = "keys/maintainers.asc"
+
+[deps.crypto]
+  import = "golang.org/x/crypto"
+  tag = "v0.1.0"
+  verify-signature = true
+[deps.tls]
+  import = "github.com/acme/tls"
+  tag = "v1.0.0"
+  verify-signature = true
+  keyring = "/etc/gopack/acme.asc"
+[deps.log]
+  import = "github.com/acme/log"
+  tag = "v1.0.0"
+`)
+
+	expected := map[string]string{
+		"golang.org/x/crypto": path.Join(pwd, "keys", "maintainers.asc"),
+		"github.com/acme/tls": "/etc/gopack/acme.asc",
+	}
+	deps := config.LoadDependencyModel(NewGraph())
+	for _, dep := range deps.DepList {
+		keyring, verify := expected[dep.Import]
+		if dep.VerifySignature != verify || (verify && dep.Keyring != keyring) {
+			t.Errorf("Expected %s to be verified with %q, but was %v with %q", dep.Import, keyring, dep.VerifySignature, dep.Keyring)
+		}
+	}
+}
+
+func TestPlatformChecksum(t *testing.T) {
+	config := setupTestConfig(`
+[deps.sys]
+  import = "golang.org/x/sys/windows"
+  commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
+  os = ["windows"]
+`)
+	config.WriteChecksum()
+
+	config.Checksum = nil
+	config.GOOS = "windows"
+	if !config.modifiedChecksum() {
+		t.Errorf("Expected the checksum to change with the platform")
+	}
+}
+
+func TestInterpolateVariables(t *testing.T) {
+	os.Setenv("GOPACK_TEST_MIRROR", "git.example.com/mirror")
+	defer os.Unsetenv("GOPACK_TEST_MIRROR")
+
+	config := setupTestConfig(`
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  source = "https://${GOPACK_TEST_MIRROR}/mux.git"
+  branch = "master"
+`)
+
+	deps, _ := config.DependencyModel(NewGraph())
+	if deps.DepList[0].Source != "https://git.example.com/mirror/mux.git" {
+		t.Errorf("Expected the source to be interpolated, but was %s", deps.DepList[0].Source)
+	}
+
+	if config.Variables["GOPACK_TEST_MIRROR"] != "git.example.com/mirror" {
+		t.Errorf("Expected the variable to be recorded for the checksum, but were %v", config.Variables)
+	}
+
+	_, err := config.interpolate("${GOPACK_TEST_UNDEFINED}/mux")
+	if err == nil || err.Error() != "references undefined variable ${GOPACK_TEST_UNDEFINED}" {
+		t.Errorf("Expected undefined variables to be an error, but was %v", err)
+	}
+}
+
+func TestConfigValueTypes(t *testing.T) {
+	tree, _ := toml.Load(`
+repo = 42
+ignore = [1, 2]
+deps = "github.com/gorilla/mux"
+`)
+
+	if _, err := getString(tree, "repo"); err == nil || err.Error() != "repo must be a string" {
+		t.Errorf("Expected a type error for repo, but was %v", err)
+	}
+
+	if _, err := getStrings(tree, "ignore"); err == nil || err.Error() != "ignore must be a list of strings" {
+		t.Errorf("Expected a type error for ignore, but was %v", err)
+	}
+
+	if _, err := getTable(tree, "deps"); err == nil || err.Error() != "deps must be a table" {
+		t.Errorf("Expected a type error for deps, but was %v", err)
+	}
+
+	if s, err := getString(tree, "missing"); s != "" || err != nil {
+		t.Errorf("Expected missing values to be empty, but were %q, %v", s, err)
+	}
+}
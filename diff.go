@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	ImportAdded   = "added"
+	ImportRemoved = "removed"
+	ImportChanged = "changed"
+)
+
+var (
+	importStatusOrder = map[string]int{ImportAdded: 0, ImportRemoved: 1, ImportChanged: 2}
+	importStatusSigns = map[string]string{ImportAdded: "+", ImportRemoved: "-", ImportChanged: "~"}
+	originNames       = map[int]string{RemoteOrigin: "remote", SelfOrigin: "project", LocalOrigin: "local", StdlibOrigin: "stdlib"}
+)
+
+// How the references to an import changed between two analyses of the source tree.
+type ImportDiff struct {
+	Path   string `json:"path"`
+	Origin string `json:"origin"`
+	// one of ImportAdded, ImportRemoved, ImportChanged
+	Status string `json:"status"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+
+	origin int
+}
+
+// The imports added, removed or referenced a different number of times,
+// added ones first and then by origin and path like the summary.
+func DiffStats(before, after *ProjectStats) []*ImportDiff {
+	diffs := []*ImportDiff{}
+	for path, s := range after.ImportStatsByPath {
+		d := &ImportDiff{Path: path, Status: ImportAdded, After: len(s.ReferencePositions), origin: s.Origin}
+		if old, found := before.ImportStatsByPath[path]; found {
+			d.Before = len(old.ReferencePositions)
+			d.Status = ImportChanged
+		}
+		if d.Before != d.After {
+			diffs = append(diffs, d)
+		}
+	}
+	for path, s := range before.ImportStatsByPath {
+		if _, found := after.ImportStatsByPath[path]; !found {
+			diffs = append(diffs, &ImportDiff{Path: path, Status: ImportRemoved, Before: len(s.ReferencePositions), origin: s.Origin})
+		}
+	}
+
+	for _, d := range diffs {
+		d.Origin = originNames[d.origin]
+	}
+	sort.Sort(importDiffs(diffs))
+	return diffs
+}
+
+func (d *ImportDiff) Legend() string {
+	return fmt.Sprintf("%s\t%s\t%s\t%d\t%d\t%+d", importStatusSigns[d.Status], originLegend(d.origin), d.Path, d.Before, d.After, d.After-d.Before)
+}
+
+type importDiffs []*ImportDiff
+
+func (d importDiffs) Len() int      { return len(d) }
+func (d importDiffs) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d importDiffs) Less(i, j int) bool {
+	if d[i].Status != d[j].Status {
+		return importStatusOrder[d[i].Status] < importStatusOrder[d[j].Status]
+	}
+	if d[i].origin != d[j].origin {
+		return d[i].origin > d[j].origin
+	}
+	return d[i].Path < d[j].Path
+}
+
+// Analyze the source tree in dir as it was at a git revision, checking it out
+// in a temporary worktree.
+func AnalyzeRevision(dir, revision string, ctx *build.Context, config *Config) (*ProjectStats, error) {
+	// dir may be a subdirectory of the repository
+	prefix, err := gitOutput(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := ioutil.TempDir("", "gopack-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		os.RemoveAll(worktree)
+		gitOutput(dir, "worktree", "prune")
+	}()
+	if _, err := gitOutput(dir, "worktree", "add", "--detach", worktree, revision); err != nil {
+		return nil, err
+	}
+
+	return AnalyzeSourceTreeContext(filepath.Join(worktree, prefix), ctx, config)
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gp stats --diff <revision> [--json]
+func printStatsDiff(config *Config, p *ProjectStats, args []string) {
+	revision := flagValue(args, "--diff", "")
+	before, err := AnalyzeRevision(config.Dir, revision, NewBuildContext(args), config)
+	if err != nil {
+		fail(err)
+	}
+	diffs := DiffStats(before, p)
+
+	if hasFlag(args, "--json") {
+		out, err := json.MarshalIndent(struct {
+			Revision string        `json:"revision"`
+			Imports  []*ImportDiff `json:"imports"`
+		}{revision, diffs}, "", "  ")
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+	fmt.Fprintf(writer, "Import stats diff against %s:\n\n", revision)
+	for _, d := range diffs {
+		fmt.Fprintln(writer, d.Legend())
+	}
+	fmt.Fprintln(writer, "\n+ added, - removed, ~ changed")
+	fmt.Fprintln(writer, "R Remote, P Project, L Local, S Stdlib")
+	writer.Flush()
+}
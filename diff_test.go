@@ -0,0 +1,78 @@
+package main
+
+import (
+	"go/build"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestDiffStats(t *testing.T) {
+	before := NewProjectStats()
+	before.foundImports(&build.Default, nil, importPositions("github.com/old/lib", "github.com/kept/lib", "fmt", "fmt"), ProductionImport)
+	after := NewProjectStats()
+	after.foundImports(&build.Default, nil, importPositions("github.com/new/lib", "github.com/kept/lib", "fmt", "fmt", "fmt"), ProductionImport)
+
+	legends := []string{}
+	for _, d := range DiffStats(before, after) {
+		legends = append(legends, d.Legend())
+	}
+	expected := []string{
+		"+\tR\tgithub.com/new/lib\t0\t1\t+1",
+		"-\tR\tgithub.com/old/lib\t1\t0\t-1",
+		"~\tS\tfmt\t2\t3\t+1",
+	}
+	if strings.Join(legends, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected diff:\n%s\nbut was:\n%s", strings.Join(expected, "\n"), strings.Join(legends, "\n"))
+	}
+}
+
+func TestAnalyzeRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	setupTestPwd()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = pwd
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=gopack", "GIT_AUTHOR_EMAIL=gopack@example.com",
+			"GIT_COMMITTER_NAME=gopack", "GIT_COMMITTER_EMAIL=gopack@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %s", strings.Join(args, " "), out)
+		}
+	}
+
+	service := path.Join(pwd, "service")
+	createSourceFixture(service, "main.go", `package main
+import "github.com/old/lib"
+`)
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "old")
+	createSourceFixture(service, "main.go", `package main
+import "github.com/new/lib"
+`)
+
+	before, err := AnalyzeRevision(service, "HEAD", &build.Default, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := before.ImportPaths(); len(paths) != 1 || paths[0] != "github.com/old/lib" {
+		t.Errorf("Expected the imports of the committed tree, but were %v", paths)
+	}
+
+	if _, err := AnalyzeRevision(service, "no-such-revision", &build.Default, nil); err == nil {
+		t.Error("Expected an error analyzing an unknown revision")
+	}
+}
+
+func importPositions(paths ...string) map[string][]token.Position {
+	positions := make(map[string][]token.Position)
+	for i, p := range paths {
+		positions[p] = append(positions[p], token.Position{Filename: "main.go", Line: i + 1})
+	}
+	return positions
+}
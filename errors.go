@@ -2,22 +2,97 @@ package main
 
 import (
 	"fmt"
+	"go/token"
+	"sort"
+	"strings"
 )
 
 const (
-	UnusedDep       = "unused-dep"
-	UnmanagedImport = "unmanaged-import"
+	UnusedDep          = "unused-dep"
+	UnmanagedImport    = "unmanaged-import"
+	TestDepImport      = "test-dep-import"
+	MissingPackage     = "missing-package"
+	ImportCycle        = "import-cycle"
+	SelfDependency     = "self-dependency"
+	DependencyConflict = "dependency-conflict"
+	DeniedLicense      = "denied-license"
+	VulnerableDep      = "vulnerable-dep"
+	DisallowedDep      = "disallowed-dep"
+	MutableDep         = "mutable-dep"
+	MovedTag           = "moved-tag"
+	UnreachableDep     = "unreachable-dep"
 )
 
+var ErrorKinds = []string{UnusedDep, UnmanagedImport, TestDepImport, MissingPackage, ImportCycle, SelfDependency, DependencyConflict, DeniedLicense, VulnerableDep, DisallowedDep, MutableDep, MovedTag, UnreachableDep}
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityOff     = "off"
+)
+
+// Severity of each kind of error, kinds not in the map are errors.
+type Severities map[string]string
+
+func (s Severities) Of(kind string) string {
+	if severity, found := s[kind]; found {
+		return severity
+	}
+	return SeverityError
+}
+
+// Split the errors by severity, dropping the ones turned off.
+func (s Severities) Split(all []*ProjectError) (errors, warnings []*ProjectError) {
+	errors = []*ProjectError{}
+	warnings = []*ProjectError{}
+	for _, e := range all {
+		switch s.Of(e.Kind) {
+		case SeverityError:
+			errors = append(errors, e)
+		case SeverityWarning:
+			warnings = append(warnings, e)
+		}
+	}
+	return
+}
+
+var severityRanks = map[string]int{SeverityOff: 0, SeverityWarning: 1, SeverityError: 2}
+
+// The strictest severity of each kind among the given ones, for problems shared by several configs.
+func StrictestSeverities(all ...Severities) Severities {
+	strictest := Severities{}
+	for _, kind := range ErrorKinds {
+		strictest[kind] = SeverityOff
+		for _, s := range all {
+			if severityRanks[s.Of(kind)] > severityRanks[strictest[kind]] {
+				strictest[kind] = s.Of(kind)
+			}
+		}
+	}
+	return strictest
+}
+
+func knownErrorKind(kind string) bool {
+	for _, k := range ErrorKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
 type ProjectError struct {
 	Kind    string
 	Message string
+	// the import path the error is about
+	Path string
 }
 
 func UnusedDependencyError(importPath string) *ProjectError {
 	return &ProjectError{
 		UnusedDep,
 		fmt.Sprintf("%s in gopack.config is unused\n", importPath),
+		importPath,
 	}
 }
 
@@ -26,6 +101,155 @@ func UnmanagedImportError(s *ImportStats) *ProjectError {
 	return &ProjectError{
 		UnmanagedImport,
 		msg,
+		s.Path,
+	}
+}
+
+func TestDependencyImportError(s *ImportStats) *ProjectError {
+	positions := []token.Position{}
+	for _, ref := range s.ReferencePositions {
+		if !strings.HasSuffix(ref.Filename, "_test.go") {
+			positions = append(positions, ref)
+		}
+	}
+	production := &ImportStats{Path: s.Path, ReferencePositions: positions}
+
+	msg := fmt.Sprintf("%s is a test dependency in gopack.config but referenced from non-test code in the following locations\n%s", s.Path, production.ReferenceList())
+	return &ProjectError{
+		TestDepImport,
+		msg,
+		s.Path,
+	}
+}
+
+func UnreachableDependencyError(importPath string, positions []token.Position) *ProjectError {
+	refs := &ImportStats{Path: importPath, ReferencePositions: positions}
+	msg := fmt.Sprintf("%s in gopack.config is only imported by code that isn't reachable from the main packages or tests, in the following locations\n%s", importPath, refs.ReferenceList())
+	return &ProjectError{
+		UnreachableDep,
+		msg,
+		importPath,
+	}
+}
+
+func MissingPackageError(s *ImportStats, dep *Dep) *ProjectError {
+	msg := fmt.Sprintf("%s referenced in the following locations is not a package of %s at %s\n%s", s.Path, dep.Import, dep.Revision(), s.ReferenceList())
+	return &ProjectError{
+		MissingPackage,
+		msg,
+		s.Path,
+	}
+}
+
+func ImportCycleError(cycle []string) *ProjectError {
+	return &ProjectError{
+		ImportCycle,
+		fmt.Sprintf("gopack.config import cycle: %s\n", strings.Join(cycle, " -> ")),
+		cycle[0],
+	}
+}
+
+func SelfDependencyError(importPath string, chain []string) *ProjectError {
+	return &ProjectError{
+		SelfDependency,
+		fmt.Sprintf("%s in %s is this project's own repository\n", importPath, requestingConfig(chain)),
+		importPath,
+	}
+}
+
+func DisallowedDependencyError(importPath, reason string, chain []string) *ProjectError {
+	return &ProjectError{
+		DisallowedDep,
+		fmt.Sprintf("%s in %s isn't allowed: %s\n", importPath, requestingConfig(chain), reason),
+		importPath,
+	}
+}
+
+func MutableDependencyError(dep *Dep, chain []string) *ProjectError {
+	checkout := "the default branch"
+	if dep.CheckoutType() != "" {
+		checkout = fmt.Sprintf("%s %s", dep.CheckoutType(), dep.CheckoutSpec)
+	}
+	return &ProjectError{
+		MutableDep,
+		fmt.Sprintf("%s in %s points at %s, which can move: pin a commit or a tag, or record its commit in gopack.lock\n", dep.Import, requestingConfig(chain), checkout),
+		dep.Import,
+	}
+}
+
+func MovedTagError(dep *Dep, recorded, revision string) *ProjectError {
+	return &ProjectError{
+		MovedTag,
+		fmt.Sprintf("%s tag %s resolves to %s but gopack.lock recorded %s, the tag was moved\n", dep.Import, dep.CheckoutSpec, revision, recorded),
+		dep.Import,
+	}
+}
+
+// The config requiring a dependency, chain holds the imports whose configs led to it.
+func requestingConfig(chain []string) string {
+	if len(chain) == 0 {
+		return "gopack.config"
+	}
+	return fmt.Sprintf("the gopack.config of %s", strings.Join(chain, " -> "))
+}
+
+// checkouts maps each checkout of the import to the workspace members requiring it.
+func DependencyConflictError(importPath string, checkouts map[string][]string) *ProjectError {
+	specs := []string{}
+	for checkout := range checkouts {
+		specs = append(specs, checkout)
+	}
+	sort.Strings(specs)
+
+	lines := []string{}
+	for _, checkout := range specs {
+		spec := strings.TrimSpace(checkout)
+		if spec == "" {
+			spec = "default branch"
+		}
+		lines = append(lines, fmt.Sprintf("  %s in %s", spec, strings.Join(checkouts[checkout], ", ")))
+	}
+	return &ProjectError{
+		DependencyConflict,
+		fmt.Sprintf("%s is required at different versions by workspace members\n%s\n", importPath, strings.Join(lines, "\n")),
+		importPath,
+	}
+}
+
+func DeniedLicenseError(l *DependencyLicense, license string) *ProjectError {
+	return &ProjectError{
+		DeniedLicense,
+		fmt.Sprintf("%s is licensed under %s, denied by the policy in gopack.config (%s)\n", l.Import, license, strings.Join(l.Files, ", ")),
+		l.Import,
+	}
+}
+
+func VulnerableDependencyError(target *AuditTarget, advisory *Advisory, affected *AffectedPackage) *ProjectError {
+	at := target.Tag
+	if at == "" {
+		at = target.Revision
+	}
+	id := advisory.ID
+	if len(advisory.Aliases) > 0 {
+		id = fmt.Sprintf("%s (%s)", id, strings.Join(advisory.Aliases, ", "))
+	}
+
+	lines := []string{}
+	for _, r := range affected.describeRanges() {
+		lines = append(lines, "  affected "+r)
+	}
+	if len(affected.Versions) > 0 {
+		lines = append(lines, "  affected versions "+strings.Join(affected.Versions, ", "))
+	}
+	if fixed := affected.fixedVersions(); len(fixed) > 0 {
+		lines = append(lines, "  fixed in "+strings.Join(fixed, ", "))
+	} else {
+		lines = append(lines, "  no fixed version")
+	}
+	return &ProjectError{
+		VulnerableDep,
+		fmt.Sprintf("%s at %s is affected by %s: %s\n%s\n", target.Dep.Import, at, id, advisory.Summary, strings.Join(lines, "\n")),
+		target.Dep.Import,
 	}
 }
 
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	tableHeader = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+	invalidKey  = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// The result of fixing validation errors in a gopack.config.
+type ConfigFix struct {
+	Content string
+	Fixed   int
+	// errors that couldn't be fixed and why
+	Skipped []string
+}
+
+// Fix the validation errors by editing the config content in place so the rest
+// of the file is preserved: unused dependencies are removed and the repositories
+// of unmanaged imports are added, pinned to the commit returned by resolve,
+// unless the policy disallows them.
+func FixConfig(content string, deps *Dependencies, errors []*ProjectError, policy *Policy, resolve func(importPath string) (string, error)) *ConfigFix {
+	fix := &ConfigFix{Skipped: []string{}}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+
+	keys := make(map[string]bool)
+	for _, k := range deps.Keys {
+		keys[k] = true
+	}
+	added := make(map[string]bool)
+
+	for _, e := range errors {
+		switch e.Kind {
+		case UnusedDep:
+			var removed bool
+			for i, importPath := range deps.Imports {
+				if importPath == e.Path && !removed {
+					lines, removed = removeTable(lines, deps.Keys[i], importPath)
+				}
+			}
+			if !removed {
+				fix.Skipped = append(fix.Skipped, fmt.Sprintf("%s: table not found", e.Path))
+				continue
+			}
+		case UnmanagedImport:
+			root := RepoRoot(e.Path)
+			if added[root] {
+				continue
+			}
+			// resolving the commit fetches the repository
+			if reason := policy.Disallows(NewDependency(root)); reason != "" {
+				fix.Skipped = append(fix.Skipped, fmt.Sprintf("%s: isn't allowed: %s", e.Path, reason))
+				continue
+			}
+			commit, err := resolve(e.Path)
+			if err != nil {
+				fix.Skipped = append(fix.Skipped, fmt.Sprintf("%s: couldn't resolve commit: %s", e.Path, err))
+				continue
+			}
+			key := tableKey(root, keys)
+			keys[key] = true
+			added[root] = true
+			lines = appendTable(lines, key, root, commit)
+		default:
+			fix.Skipped = append(fix.Skipped, fmt.Sprintf("%s: %s errors can't be fixed automatically", e.Path, e.Kind))
+			continue
+		}
+		fix.Fixed++
+	}
+
+	fix.Content = strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+	return fix
+}
+
+// Remove the [deps.key] or [test-deps.key] table importing importPath,
+// up to the comments of the next table header.
+func removeTable(lines []string, key, importPath string) ([]string, bool) {
+	for start, line := range lines {
+		m := tableHeader.FindStringSubmatch(line)
+		if m == nil || (m[1] != "deps."+key && m[1] != "test-deps."+key) {
+			continue
+		}
+
+		end := start + 1
+		for end < len(lines) && !tableHeader.MatchString(lines[end]) {
+			end++
+		}
+		// comments right above the next header belong to the next table
+		if end < len(lines) {
+			for i := end - 1; i > start && (isComment(lines[i]) || strings.TrimSpace(lines[i]) == ""); i-- {
+				if isComment(lines[i]) {
+					end = i
+				}
+			}
+		}
+
+		if !strings.Contains(strings.Join(lines[start:end], "\n"), fmt.Sprintf("%q", importPath)) {
+			continue
+		}
+
+		removed := append([]string{}, lines[:start]...)
+		return append(removed, lines[end:]...), true
+	}
+	return lines, false
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+func appendTable(lines []string, key, importPath, commit string) []string {
+	if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+		lines = append(lines, "")
+	}
+	return append(lines,
+		fmt.Sprintf("[deps.%s]", key),
+		fmt.Sprintf("import = %q", importPath),
+		fmt.Sprintf("commit = %q", commit))
+}
+
+// A table key for the import that isn't used yet, based on its last path element.
+func tableKey(importPath string, keys map[string]bool) string {
+	base := strings.Trim(invalidKey.ReplaceAllString(path.Base(importPath), "-"), "-")
+	if base == "" {
+		base = "dep"
+	}
+	key := base
+	for i := 2; keys[key]; i++ {
+		key = fmt.Sprintf("%s-%d", base, i)
+	}
+	return key
+}
+
+// The commit of the repository providing importPath in the vendor tree,
+// which is fetched first if it isn't there yet, the way the resolver would.
+func resolveCommit(importPath string, r *Resolver) (string, error) {
+	d := NewDependency(importPath)
+	if _, err := os.Stat(d.Src()); os.IsNotExist(err) {
+		if reason := r.resolveClone(d); reason != "" {
+			return "", fmt.Errorf("%s", reason)
+		}
+		fmtcolor(Gray, "fetching %s\n", importPath)
+		d.fetch = true
+		if err := d.goGetUpdate(); err != nil {
+			return "", err
+		}
+	}
+
+	scm, err := d.Scm()
+	if err != nil {
+		return "", err
+	}
+	return scm.Revision(d)
+}
+
+// gp fix [--yes]
+func fixConfiguration(config *Config, p *ProjectStats, args []string) {
+	importGraph := NewGraph()
+	config.InitRepo(importGraph)
+
+	deps, _ := config.DependencyModel(importGraph)
+	if deps == nil {
+		deps = &Dependencies{ImportGraph: importGraph}
+	}
+
+	errors := deps.Validate(p)
+	if len(errors) == 0 {
+		fmt.Println("nothing to fix")
+		return
+	}
+
+	dat, err := ioutil.ReadFile(config.Path)
+	if err != nil {
+		fail(err)
+	}
+	content := string(dat)
+
+	resolver := NewResolver(config.Repository)
+	resolver.Policies = []*Policy{config.Policy}
+	fix := FixConfig(content, deps, errors, config.Policy, func(importPath string) (string, error) {
+		return resolveCommit(importPath, resolver)
+	})
+	for _, s := range fix.Skipped {
+		fmtcolor(Gray, "skipping %s\n", s)
+	}
+
+	if fix.Content == content {
+		return
+	}
+
+	printDiff(config.Path, content, fix.Content)
+
+	if !hasFlag(args, "--yes", "-y") && !confirm("Apply these changes?") {
+		return
+	}
+
+	err = ioutil.WriteFile(config.Path, []byte(fix.Content), 0644)
+	if err != nil {
+		fail(err)
+	}
+	fmtcolor(Green, "fixed %d errors in %s\n", fix.Fixed, config.Path)
+}
+
+func hasFlag(args []string, names ...string) bool {
+	for _, arg := range args {
+		for _, name := range names {
+			if arg == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func printDiff(name, a, b string) {
+	fmt.Printf("--- %s\n+++ %s\n", name, name)
+	for _, line := range DiffLines(strings.Split(a, "\n"), strings.Split(b, "\n")) {
+		switch line[0] {
+		case '-':
+			fmtcolor(Red, "%s\n", line)
+		case '+':
+			fmtcolor(Green, "%s\n", line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+// A line diff of a and b in unified format with two lines of context.
+func DiffLines(a, b []string) []string {
+	// longest common subsequence lengths of the suffixes
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte
+		line string
+		a, b int
+	}
+	edits := []edit{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		} else if i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]) {
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		} else {
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	const context = 2
+	out := []string{}
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// extend the hunk while changes are closer than twice the context
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context + 1
+		if to > len(edits) {
+			to = len(edits)
+		}
+
+		aLines, bLines := 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				aLines++
+			}
+			if e.op != '-' {
+				bLines++
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", edits[from].a+1, aLines, edits[from].b+1, bLines))
+		for _, e := range edits[from:to] {
+			out = append(out, string(e.op)+e.line)
+		}
+		start = to
+	}
+	return out
+}
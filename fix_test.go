@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFixConfig(t *testing.T) {
+	fixture := `repo = "github.com/d2fn/gopack"
+
+# web framework
+[deps.mux]
+import = "github.com/gorilla/mux"
+branch = "master"
+
+[deps.toml]
+import = "github.com/pelletier/go-toml"
+commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+`
+	config := setupTestConfig(fixture)
+	deps, _ := config.DependencyModel(NewGraph())
+
+	errors := []*ProjectError{
+		UnmanagedImportError(&ImportStats{Path: "code.google.com/p/go.net/websocket"}),
+		UnmanagedImportError(&ImportStats{Path: "code.google.com/p/go.net/html"}),
+		UnmanagedImportError(&ImportStats{Path: "github.com/bradfitz/gomemcache/memcache"}),
+		UnusedDependencyError("github.com/gorilla/mux"),
+		TestDependencyImportError(&ImportStats{Path: "github.com/stretchr/testify/assert"}),
+	}
+
+	resolve := func(importPath string) (string, error) {
+		if strings.HasPrefix(importPath, "github.com/bradfitz") {
+			return "", fmt.Errorf("not found")
+		}
+		return "5f8e3d2b", nil
+	}
+
+	fix := FixConfig(fixture, deps, errors, config.Policy, resolve)
+
+	expected := `repo = "github.com/d2fn/gopack"
+
+# web framework
+[deps.toml]
+import = "github.com/pelletier/go-toml"
+commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+
+[deps.go-net]
+import = "code.google.com/p/go.net"
+commit = "5f8e3d2b"
+`
+	if fix.Content != expected {
+		t.Errorf("Expected fixed config to be\n%s\nbut was\n%s", expected, fix.Content)
+	}
+
+	if fix.Fixed != 2 {
+		t.Errorf("Expected to fix 2 errors, but fixed %d", fix.Fixed)
+	}
+
+	if len(fix.Skipped) != 2 {
+		t.Errorf("Expected to skip 2 errors, but skipped %v", fix.Skipped)
+	}
+}
+
+func TestFixConfigKeepsCommentsOfNextTable(t *testing.T) {
+	fixture := `[deps.mux]
+import = "github.com/gorilla/mux"
+branch = "master"
+
+# configuration
+# pinned until the 1.0 release
+[deps.toml]
+import = "github.com/pelletier/go-toml"
+commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+`
+	config := setupTestConfig(fixture)
+	deps, _ := config.DependencyModel(NewGraph())
+
+	fix := FixConfig(fixture, deps, []*ProjectError{UnusedDependencyError("github.com/gorilla/mux")}, config.Policy, nil)
+
+	expected := `# configuration
+# pinned until the 1.0 release
+[deps.toml]
+import = "github.com/pelletier/go-toml"
+commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+`
+	if fix.Content != expected {
+		t.Errorf("Expected fixed config to be\n%s\nbut was\n%s", expected, fix.Content)
+	}
+}
+
+func TestFixConfigKeepsKeysUnique(t *testing.T) {
+	fixture := `[deps.mux]
+import = "github.com/gorilla/mux"
+branch = "master"
+`
+	config := setupTestConfig(fixture)
+	deps, _ := config.DependencyModel(NewGraph())
+
+	errors := []*ProjectError{
+		UnmanagedImportError(&ImportStats{Path: "github.com/other/mux"}),
+	}
+
+	fix := FixConfig(fixture, deps, errors, config.Policy, func(string) (string, error) { return "abc", nil })
+	if !strings.Contains(fix.Content, "[deps.mux-2]\nimport = \"github.com/other/mux\"") {
+		t.Errorf("Expected a unique key for the new table, but config was\n%s", fix.Content)
+	}
+}
+
+func TestFixConfigPolicy(t *testing.T) {
+	fixture := `[policy]
+allow-hosts = ["github.com"]
+deny-imports = ["github.com/evil/lib"]
+`
+	config := setupTestConfig(fixture)
+	deps := &Dependencies{ImportGraph: NewGraph()}
+
+	errors := []*ProjectError{
+		UnmanagedImportError(&ImportStats{Path: "github.com/evil/lib/sub"}),
+		UnmanagedImportError(&ImportStats{Path: "gitlab.com/acme/lib"}),
+		UnmanagedImportError(&ImportStats{Path: "github.com/acme/lib"}),
+	}
+
+	resolved := []string{}
+	fix := FixConfig(fixture, deps, errors, config.Policy, func(importPath string) (string, error) {
+		resolved = append(resolved, importPath)
+		return "abc", nil
+	})
+	if strings.Join(resolved, " ") != "github.com/acme/lib" {
+		t.Errorf("Expected only the allowed import to be fetched, but fetched %v", resolved)
+	}
+	expected := []string{
+		"github.com/evil/lib/sub: isn't allowed: github.com/evil/lib is denied by the policy",
+		"gitlab.com/acme/lib: isn't allowed: it's fetched from gitlab.com, which isn't one of the allowed hosts",
+	}
+	if strings.Join(fix.Skipped, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected skipped imports:\n%s\nbut were:\n%s", strings.Join(expected, "\n"), strings.Join(fix.Skipped, "\n"))
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := strings.Split("a\nb\nc\nd\ne\nf\ng\nh\ni\nj", "\n")
+	b := strings.Split("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk", "\n")
+
+	expected := `@@ -1,4 +1,4 @@
+ a
+-b
++B
+ c
+ d
+@@ -9,2 +9,3 @@
+ i
+ j
++k`
+
+	actual := strings.Join(DiffLines(a, b), "\n")
+	if actual != expected {
+		t.Errorf("Expected diff to be\n%s\nbut was\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// version required for modules replaced by a directory
+	placeholderVersion = "v0.0.0-00010101000000-000000000000"
+)
+
+var (
+	semver       = regexp.MustCompile(`^v([0-9]+)\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	moduleLine   = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+	sourceScheme = regexp.MustCompile(`^[a-z+]+://([^@/]+@)?`)
+	scpSource    = regexp.MustCompile(`^[^@/]+@([^:/]+):(.+)$`)
+)
+
+// A module required by the exported go.mod.
+type ModuleRequirement struct {
+	Path    string
+	Version string
+	// module or directory replacing this one, for forks and local checkouts
+	Replace        string
+	ReplaceVersion string
+	// source of the module in the vendor tree, hashed into go.sum
+	Dir string
+}
+
+// Whether the module is replaced by a local directory, which has no go.sum entry.
+func (r *ModuleRequirement) Local() bool {
+	return r.Replace != "" && r.ReplaceVersion == ""
+}
+
+// The module version of a dependency: tags that are valid semantic versions are
+// used as they are, anything else is pinned with a pseudo-version of the commit
+// checked out, including tags with build metadata other than +incompatible, which
+// go doesn't accept as versions. goMod is the content of the go.mod of the dependency, if any.
+func ModuleVersion(dep *Dep, goMod []byte, revision string, commitTime time.Time) (string, error) {
+	if dep.CheckoutFlag == TagFlag {
+		if m := moduleTag(dep); m != nil {
+			major, _ := strconv.Atoi(m[1])
+			if major < 2 || m[3] != "" {
+				return dep.CheckoutSpec, nil
+			}
+			if goMod != nil {
+				return "", fmt.Errorf("tag %s needs the /v%d module path", dep.CheckoutSpec, major)
+			}
+			return dep.CheckoutSpec + "+incompatible", nil
+		}
+	}
+
+	if len(revision) < 12 {
+		return "", fmt.Errorf("revision %q can't be used in a pseudo-version", revision)
+	}
+	return PseudoVersion(revision, commitTime), nil
+}
+
+// The parts of the semantic version of the tag, nil unless go accepts it as a version.
+func moduleTag(dep *Dep) []string {
+	m := semver.FindStringSubmatch(dep.CheckoutSpec)
+	if dep.CheckoutFlag != TagFlag || m == nil || (m[3] != "" && m[3] != "+incompatible") {
+		return nil
+	}
+	return m
+}
+
+// The v0.0.0 pseudo-version of a commit.
+func PseudoVersion(revision string, commitTime time.Time) string {
+	return fmt.Sprintf("v0.0.0-%s-%s", commitTime.UTC().Format("20060102150405"), revision[:12])
+}
+
+// The module path of a source repository URL,
+// https://git.example.com/mirror/net.git or git@git.example.com:mirror/net.git
+// for instance are git.example.com/mirror/net.git.
+func SourceModulePath(source string) string {
+	if m := scpSource.FindStringSubmatch(source); m != nil && !strings.Contains(source, "://") {
+		return m[1] + "/" + m[2]
+	}
+	return strings.TrimSuffix(sourceScheme.ReplaceAllString(source, ""), "/")
+}
+
+// The go.mod of the module requiring reqs.
+func WriteGoMod(w io.Writer, module string, reqs []*ModuleRequirement) {
+	fmt.Fprintf(w, "module %s\n", module)
+
+	if len(reqs) > 0 {
+		fmt.Fprintln(w, "\nrequire (")
+		for _, r := range reqs {
+			fmt.Fprintf(w, "\t%s %s\n", r.Path, r.Version)
+		}
+		fmt.Fprintln(w, ")")
+	}
+
+	replaced := []*ModuleRequirement{}
+	for _, r := range reqs {
+		if r.Replace != "" {
+			replaced = append(replaced, r)
+		}
+	}
+	if len(replaced) > 0 {
+		fmt.Fprintln(w, "\nreplace (")
+		for _, r := range replaced {
+			fmt.Fprintf(w, "\t%s => %s", r.Path, r.Replace)
+			if r.ReplaceVersion != "" {
+				fmt.Fprintf(w, " %s", r.ReplaceVersion)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, ")")
+	}
+}
+
+// The go.sum lines of the modules, hashing their sources in the vendor tree
+// the way the go command hashes module zips.
+func WriteGoSum(w io.Writer, reqs []*ModuleRequirement) error {
+	lines := []string{}
+	for _, r := range reqs {
+		if r.Local() {
+			continue
+		}
+		path, version := r.Path, r.Version
+		if r.Replace != "" {
+			path, version = r.Replace, r.ReplaceVersion
+		}
+		prefix := path + "@" + version
+
+		dirHash, err := HashModuleDir(r.Dir, prefix)
+		if err != nil {
+			return err
+		}
+		goMod, err := moduleGoMod(r.Dir)
+		if err != nil {
+			return err
+		}
+		if goMod == nil {
+			goMod = []byte(fmt.Sprintf("module %s\n", path))
+		}
+		modHash := hashFiles(map[string][]byte{prefix + "/go.mod": goMod})
+
+		lines = append(lines,
+			fmt.Sprintf("%s %s %s", path, version, dirHash),
+			fmt.Sprintf("%s %s/go.mod %s", path, version, modHash))
+	}
+
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// The h1 hash of the files of the module in dir, leaving out the scm metadata,
+// vendor directories, nested modules and anything but regular files.
+func HashModuleDir(dir, prefix string) (string, error) {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path == dir {
+				return nil
+			}
+			for _, scm := range append(scmDirs, ".bzr", "vendor") {
+				if info.Name() == scm {
+					return filepath.SkipDir
+				}
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[prefix+"/"+relativePath(dir, path)] = dat
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hashFiles(files), nil
+}
+
+// The h1 hash of files by name: the sha256 of the sorted list
+// of the sha256 of each file followed by its name.
+func hashFiles(files map[string][]byte) string {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%x  %s\n", sha256.Sum256(files[name]), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// The go.mod in dir, nil when there's none.
+func moduleGoMod(dir string) ([]byte, error) {
+	dat, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return dat, err
+}
+
+// The module requirement of each repository the dependencies are fetched from.
+// Dependencies that can't be expressed as modules are returned as errors.
+func ModuleRequirements(deps []*Dep) ([]*ModuleRequirement, []string) {
+	byPath := make(map[string]*ModuleRequirement)
+	problems := []string{}
+
+	for _, dep := range deps {
+		root := NewDependency(RepoRoot(dep.Import))
+		root.CheckoutFlag, root.CheckoutSpec, root.Source = dep.CheckoutFlag, dep.CheckoutSpec, dep.Source
+
+		r, err := moduleRequirement(root)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", dep.Import, err))
+			continue
+		}
+		if existing, found := byPath[r.Path]; found {
+			if existing.Version != r.Version {
+				problems = append(problems, fmt.Sprintf("%s: required at %s and %s", r.Path, existing.Version, r.Version))
+			}
+			continue
+		}
+		byPath[r.Path] = r
+	}
+
+	reqs := []*ModuleRequirement{}
+	for _, r := range byPath {
+		reqs = append(reqs, r)
+	}
+	sort.Sort(requirementsByPath(reqs))
+	sort.Strings(problems)
+	return reqs, problems
+}
+
+func moduleRequirement(dep *Dep) (*ModuleRequirement, error) {
+	r := &ModuleRequirement{Path: dep.Import, Dir: dep.Src()}
+
+	info, err := os.Lstat(r.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		// a local checkout linked into the vendor tree
+		target, err := filepath.EvalSymlinks(r.Dir)
+		if err != nil {
+			return nil, err
+		}
+		r.Version = placeholderVersion
+		r.Replace = target
+		return r, nil
+	}
+
+	goMod, err := moduleGoMod(r.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if m := moduleLine.FindSubmatch(goMod); m != nil && string(m[1]) != dep.Import {
+		return nil, fmt.Errorf("its go.mod declares module %s", m[1])
+	}
+
+	revision, commitTime := "", time.Time{}
+	if moduleTag(dep) == nil {
+		scm, err := dep.Scm()
+		if err != nil {
+			return nil, err
+		}
+		if revision, err = scm.Revision(dep); err != nil {
+			return nil, err
+		}
+		if commitTime, err = scm.CommitTime(dep); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Version, err = ModuleVersion(dep, goMod, revision, commitTime)
+	if err != nil {
+		return nil, err
+	}
+	if dep.Source != "" {
+		r.Replace = SourceModulePath(dep.Source)
+		r.ReplaceVersion = r.Version
+	}
+	return r, nil
+}
+
+type requirementsByPath []*ModuleRequirement
+
+func (r requirementsByPath) Len() int           { return len(r) }
+func (r requirementsByPath) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r requirementsByPath) Less(i, j int) bool { return r[i].Path < r[j].Path }
+
+// gp export gomod [--force]
+func exportGoMod(config *Config, p *ProjectStats, args []string) {
+	if config.Repository == "" {
+		failf("%s - repo is needed as the module path\n", config.Path)
+	}
+	goModPath := filepath.Join(config.Dir, "go.mod")
+	if _, err := os.Stat(goModPath); err == nil && !hasFlag(args, "--force") {
+		failf("%s already exists, use --force to overwrite it\n", goModPath)
+	}
+
+	reqs, problems := ModuleRequirements(resolveDependencies(config, p))
+	if len(problems) > 0 {
+		failf("can't export go.mod:\n  %s\n", strings.Join(problems, "\n  "))
+	}
+
+	var goMod, goSum bytes.Buffer
+	WriteGoMod(&goMod, config.Repository, reqs)
+	if err := WriteGoSum(&goSum, reqs); err != nil {
+		fail(err)
+	}
+
+	if err := ioutil.WriteFile(goModPath, goMod.Bytes(), 0644); err != nil {
+		fail(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(config.Dir, "go.sum"), goSum.Bytes(), 0644); err != nil {
+		fail(err)
+	}
+	fmtcolor(Green, "exported %d modules to %s\n", len(reqs), goModPath)
+}
+
+// gp export <format>
+func exportCommand(config *Config, p *ProjectStats, args []string) {
+	if len(args) == 0 || args[0] != "gomod" {
+		failf("usage: gp export gomod [--force]\n")
+	}
+	exportGoMod(config, p, args[1:])
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestModuleVersion(t *testing.T) {
+	commitTime := time.Date(2016, 8, 2, 10, 4, 5, 0, time.UTC)
+	revision := "9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e"
+
+	cases := []struct {
+		dep      *Dep
+		goMod    []byte
+		expected string
+	}{
+		{&Dep{CheckoutFlag: TagFlag, CheckoutSpec: "v1.6.2"}, nil, "v1.6.2"},
+		{&Dep{CheckoutFlag: TagFlag, CheckoutSpec: "v3.0.0"}, nil, "v3.0.0+incompatible"},
+		{&Dep{CheckoutFlag: TagFlag, CheckoutSpec: "v3.0.0+incompatible"}, nil, "v3.0.0+incompatible"},
+		{&Dep{CheckoutFlag: TagFlag, CheckoutSpec: "v1.2.0+build.5"}, nil, "v0.0.0-20160802100405-9fa818a44c2b"},
+		{&Dep{CheckoutFlag: TagFlag, CheckoutSpec: "1.0rc2"}, nil, "v0.0.0-20160802100405-9fa818a44c2b"},
+		{&Dep{CheckoutFlag: BranchFlag, CheckoutSpec: "master"}, nil, "v0.0.0-20160802100405-9fa818a44c2b"},
+		{&Dep{CheckoutFlag: CommitFlag, CheckoutSpec: revision}, nil, "v0.0.0-20160802100405-9fa818a44c2b"},
+	}
+
+	for _, c := range cases {
+		version, err := ModuleVersion(c.dep, c.goMod, revision, commitTime)
+		if err != nil || version != c.expected {
+			t.Errorf("Expected %s for %s, but was %s (%v)", c.expected, c.dep.CheckoutSpec, version, err)
+		}
+	}
+
+	_, err := ModuleVersion(&Dep{CheckoutFlag: TagFlag, CheckoutSpec: "v2.1.0"}, []byte("module github.com/acme/lib\n"), revision, commitTime)
+	if err == nil || err.Error() != "tag v2.1.0 needs the /v2 module path" {
+		t.Errorf("Expected major versions of modules to be refused, but was %v", err)
+	}
+
+	_, err = ModuleVersion(&Dep{CheckoutFlag: CommitFlag, CheckoutSpec: "42"}, nil, "42", commitTime)
+	if err == nil {
+		t.Errorf("Expected svn revisions to be refused")
+	}
+}
+
+func TestSourceModulePath(t *testing.T) {
+	for source, expected := range map[string]string{
+		"https://git.example.com/mirror/net.git":   "git.example.com/mirror/net.git",
+		"ssh://git@git.example.com/mirror/net.git": "git.example.com/mirror/net.git",
+		"git@git.example.com:mirror/net.git":       "git.example.com/mirror/net.git",
+		"git.example.com/mirror/net":               "git.example.com/mirror/net",
+	} {
+		if path := SourceModulePath(source); path != expected {
+			t.Errorf("Expected %s for %s, but was %s", expected, source, path)
+		}
+	}
+}
+
+func TestWriteGoMod(t *testing.T) {
+	reqs := []*ModuleRequirement{
+		{Path: "github.com/gorilla/mux", Version: "v1.6.2"},
+		{Path: "golang.org/x/net", Version: "v0.0.0-20160802100405-9fa818a44c2b", Replace: "git.example.com/mirror/net.git", ReplaceVersion: "v0.0.0-20160802100405-9fa818a44c2b"},
+		{Path: "github.com/acme/lib", Version: placeholderVersion, Replace: "/src/lib"},
+	}
+
+	var w bytes.Buffer
+	WriteGoMod(&w, "github.com/acme/api", reqs)
+
+	expected := `module github.com/acme/api
+
+require (
+	github.com/gorilla/mux v1.6.2
+	golang.org/x/net v0.0.0-20160802100405-9fa818a44c2b
+	github.com/acme/lib v0.0.0-00010101000000-000000000000
+)
+
+replace (
+	golang.org/x/net => git.example.com/mirror/net.git v0.0.0-20160802100405-9fa818a44c2b
+	github.com/acme/lib => /src/lib
+)
+`
+	if w.String() != expected {
+		t.Errorf("Expected go.mod:\n%s\nbut was:\n%s", expected, w.String())
+	}
+}
+
+func TestWriteGoSum(t *testing.T) {
+	setupTestPwd()
+	dep := createScmDep(".git", "github.com/acme/lib", "objects")
+	createSourceFixture(dep.Src(), "lib.go", "package lib\n")
+	createSourceFixture(dep.Src(), "LICENSE", "MIT\n")
+	createSourceFixture(path.Join(dep.Src(), "vendor", "github.com", "acme", "other"), "other.go", "package other\n")
+	createSourceFixture(path.Join(dep.Src(), "v2"), "go.mod", "module github.com/acme/lib/v2\n")
+
+	reqs := []*ModuleRequirement{
+		{Path: "github.com/acme/lib", Version: "v1.0.0", Dir: dep.Src()},
+		{Path: "github.com/acme/local", Version: placeholderVersion, Replace: "/src/local"},
+	}
+
+	var w bytes.Buffer
+	err := WriteGoSum(&w, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hashes computed with golang.org/x/mod/sumdb/dirhash
+	expected := `github.com/acme/lib v1.0.0 h1:mkqUpdXIdXTWHEDGgzumd6blSAIyjTVjLd0DYCDvf2U=
+github.com/acme/lib v1.0.0/go.mod h1:E4WApeIjuG60sKofm1TwvucIo47RbTdE9dtpnAp4HVs=
+`
+	if w.String() != expected {
+		t.Errorf("Expected go.sum:\n%s\nbut was:\n%s", expected, w.String())
+	}
+}
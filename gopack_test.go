@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"go/build"
+	"path"
 	"testing"
 )
 
@@ -27,10 +29,154 @@ func TestUnmanagedImport(t *testing.T) {
 	}
 }
 
+func TestTestDependencyImport(t *testing.T) {
+	dir := fmt.Sprintf("%s/test-dep-import", GopackTestProjects)
+	errors := findErrors(dir, t)
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, found %d\n", len(errors))
+	}
+	e := errors[0]
+	if e.Kind != TestDepImport {
+		t.Errorf("expected test dependency import error\n")
+	}
+
+	expected := fmt.Sprintf("github.com/stretchr/testify/assert is a test dependency in gopack.config but referenced from non-test code in the following locations\n* %s/main.go:5", dir)
+	if e.String() != expected {
+		t.Errorf("expected error to be %q but it was %q\n", expected, e.String())
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	errors := findErrors(fmt.Sprintf("%s/ignore-rules", GopackTestProjects), t)
+	if len(errors) != 0 {
+		t.Fatalf("expected no errors, found %d\n", len(errors))
+	}
+}
+
+func TestSubPackageDependency(t *testing.T) {
+	errors := findErrors(fmt.Sprintf("%s/subpackage-dep", GopackTestProjects), t)
+	if len(errors) != 0 {
+		t.Fatalf("expected no errors, found %d\n", len(errors))
+	}
+}
+
+func TestNestedImport(t *testing.T) {
+	errors := findErrors(fmt.Sprintf("%s/nested-import", GopackTestProjects), t)
+	if len(errors) != 0 {
+		t.Fatalf("expected no errors, found %d\n", len(errors))
+	}
+}
+
+func TestOverlappingDependencies(t *testing.T) {
+	errors := findErrors(fmt.Sprintf("%s/overlapping-deps", GopackTestProjects), t)
+	if len(errors) != 0 {
+		t.Fatalf("expected no errors, found %d\n", len(errors))
+	}
+}
+
+func TestPrefixNameIsAnotherRepository(t *testing.T) {
+	errors := findErrors(fmt.Sprintf("%s/prefix-name", GopackTestProjects), t)
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors, found %d\n", len(errors))
+	}
+	if errors[0].Kind != UnmanagedImport {
+		t.Errorf("expected unmanaged import error\n")
+	}
+	if errors[1].Kind != UnusedDep {
+		t.Errorf("expected unused dependency error\n")
+	}
+}
+
+func TestMissingPackage(t *testing.T) {
+	setupTestPwd()
+	createScmDep(".git", "github.com/foo/bar")
+	createSourceFixture(path.Join(pwd, VendorDir, "src", "github.com/foo/bar/here"), "here.go", "package here\n")
+
+	dir := fmt.Sprintf("%s/missing-package", GopackTestProjects)
+	c := NewConfig(dir)
+	d, _ := c.DependencyModel(NewGraph())
+	p, err := AnalyzeSourceTreeContext(dir, &build.Default, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errors := d.ValidatePackages(p)
+	PrintErrors(errors, t)
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, found %d\n", len(errors))
+	}
+
+	e := errors[0]
+	if e.Kind != MissingPackage || e.Path != "github.com/foo/bar/gone" {
+		t.Errorf("expected missing package error for github.com/foo/bar/gone\n")
+	}
+
+	expected := fmt.Sprintf("github.com/foo/bar/gone referenced in the following locations is not a package of github.com/foo/bar at tag v1.0.0\n* %s/main.go:4", dir)
+	if e.String() != expected {
+		t.Errorf("expected error to be %q but it was %q\n", expected, e.String())
+	}
+}
+
+func TestValidationErrorsAreSorted(t *testing.T) {
+	dir := fmt.Sprintf("%s/multiple-errors", GopackTestProjects)
+	errors := findErrors(dir, t)
+
+	expected := []string{
+		fmt.Sprintf("github.com/bradfitz/gomemcache/memcache referenced in the following locations but not managed in gopack.config\n* %s/cache.go:4", dir),
+		fmt.Sprintf("github.com/gorilla/mux referenced in the following locations but not managed in gopack.config\n* %s/cache.go:5\n* %s/main.go:4", dir, dir),
+		"github.com/d2fn/aaa in gopack.config is unused\n",
+		"github.com/d2fn/zzz in gopack.config is unused\n",
+	}
+
+	if len(errors) != len(expected) {
+		t.Fatalf("expected %d errors, found %d\n", len(expected), len(errors))
+	}
+	for i, e := range errors {
+		if e.String() != expected[i] {
+			t.Errorf("expected error %d to be %q but it was %q\n", i, expected[i], e.String())
+		}
+	}
+}
+
+func TestValidationSeverities(t *testing.T) {
+	all := findErrors(fmt.Sprintf("%s/multiple-errors", GopackTestProjects), t)
+
+	severities := Severities{UnusedDep: SeverityWarning, UnmanagedImport: SeverityOff}
+	errors, warnings := severities.Split(all)
+	if len(errors) != 0 {
+		t.Errorf("expected no errors, found %d\n", len(errors))
+	}
+	if len(warnings) != 2 || warnings[0].Kind != UnusedDep {
+		t.Errorf("expected 2 unused dependency warnings, found %d\n", len(warnings))
+	}
+
+	errors, warnings = Severities{}.Split(all)
+	if len(errors) != 4 || len(warnings) != 0 {
+		t.Errorf("expected every error to be reported as an error\n")
+	}
+}
+
+func TestStrictestSeverities(t *testing.T) {
+	strictest := StrictestSeverities(
+		Severities{UnusedDep: SeverityWarning, DependencyConflict: SeverityOff, MutableDep: SeverityOff},
+		Severities{UnusedDep: SeverityOff, DependencyConflict: SeverityWarning},
+	)
+	for kind, expected := range map[string]string{
+		UnusedDep:          SeverityWarning,
+		DependencyConflict: SeverityWarning,
+		MutableDep:         SeverityError,
+		ImportCycle:        SeverityError,
+	} {
+		if severity := strictest.Of(kind); severity != expected {
+			t.Errorf("expected %s to be %s, found %s\n", kind, expected, severity)
+		}
+	}
+}
+
 func findErrors(dir string, t *testing.T) []*ProjectError {
 	c := NewConfig(dir)
 	d := c.LoadDependencyModel(NewGraph())
-	p, err := AnalyzeSourceTree(dir)
+	p, err := AnalyzeSourceTreeContext(dir, &build.Default, c)
 	if err != nil {
 		t.Fatal(err)
 	}
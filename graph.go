@@ -1,6 +1,7 @@
 package main
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -11,8 +12,10 @@ type Graph struct {
 type Node struct {
 	Key        string
 	Dependency *Dep
-	Leaf       bool
-	Nodes      map[string]*Node
+	// The node holds a dependency,
+	// other dependencies may still be nested below it.
+	Leaf  bool
+	Nodes map[string]*Node
 }
 
 func NewGraph() *Graph {
@@ -24,24 +27,59 @@ func (graph *Graph) Insert(dependency *Dep) {
 	graph.Nodes[keys[0]] = deepInsert(graph.Nodes, keys, dependency)
 }
 
+// Find the dependency with the longest import path that is a prefix of importPath.
 func (graph *Graph) Search(importPath string) *Node {
 	keys := strings.Split(importPath, "/")
 
+	var found *Node
 	nodes := graph.Nodes
 	for _, key := range keys {
 		node := nodes[key]
 		if node == nil {
-			return nil
+			break
 		}
 
 		if node.Leaf {
-			return node
+			found = node
 		}
 
 		nodes = node.Nodes
 	}
 
-	return nil
+	return found
+}
+
+// Find a dependency fetched from the same repository as importPath,
+// for imports of a repository above the packages declared as dependencies.
+func (graph *Graph) SearchRepository(importPath string) *Node {
+	if node := graph.Search(importPath); node != nil {
+		return node
+	}
+
+	keys := strings.Split(RepoRoot(importPath), "/")
+
+	nodes := graph.Nodes
+	var root *Node
+	for _, key := range keys {
+		root = nodes[key]
+		if root == nil {
+			return nil
+		}
+		nodes = root.Nodes
+	}
+
+	if root.Leaf {
+		return root
+	}
+
+	var found *Node
+	root.PreOrderVisit(
+		func(n *Node, depth int) {
+			if found == nil && n.Leaf {
+				found = n
+			}
+		}, 0)
+	return found
 }
 
 func deepInsert(nodes map[string]*Node, keys []string, dependency *Dep) *Node {
@@ -61,17 +99,26 @@ func deepInsert(nodes map[string]*Node, keys []string, dependency *Dep) *Node {
 	return node
 }
 
+// Visit every node in the graph, siblings in lexical order.
 func (graph *Graph) PreOrderVisit(fn func(n *Node, depth int)) {
-	for _, node := range graph.Nodes {
-		node.PreOrderVisit(fn, 0)
+	for _, key := range sortedKeys(graph.Nodes) {
+		graph.Nodes[key].PreOrderVisit(fn, 0)
 	}
 }
 
 func (parent *Node) PreOrderVisit(fn func(n *Node, depth int), depth int) {
-	for _, node := range parent.Nodes {
+	for _, key := range sortedKeys(parent.Nodes) {
+		node := parent.Nodes[key]
 		fn(node, depth)
-		if !node.Leaf {
-			node.PreOrderVisit(fn, depth+1)
-		}
+		node.PreOrderVisit(fn, depth+1)
+	}
+}
+
+func sortedKeys(nodes map[string]*Node) []string {
+	keys := make([]string, 0, len(nodes))
+	for key := range nodes {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
 }
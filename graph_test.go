@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 )
@@ -128,3 +129,81 @@ func TestSearchWorksWithExtendedNames(t *testing.T) {
 		t.Error("Expected search to succeed importing extended repos")
 	}
 }
+
+func TestSearchFindsLongestPrefix(t *testing.T) {
+	graph := NewGraph()
+	bar := &Dep{Import: "github.com/foo/bar"}
+	baz := &Dep{Import: "github.com/foo/bar/baz"}
+	graph.Insert(baz)
+	graph.Insert(bar)
+
+	if node := graph.Search("github.com/foo/bar/baz/qux"); node == nil || node.Dependency != baz {
+		t.Error("Expected search to find the nested dependency")
+	}
+
+	if node := graph.Search("github.com/foo/bar/other"); node == nil || node.Dependency != bar {
+		t.Error("Expected search to find the outer dependency")
+	}
+}
+
+func TestSearchFailsAboveDependency(t *testing.T) {
+	graph := NewGraph()
+	graph.Insert(&Dep{Import: "github.com/foo/bar/baz"})
+
+	if graph.Search("github.com/foo/bar") != nil {
+		t.Error("Expected search to fail above the dependency")
+	}
+}
+
+func TestSearchRepository(t *testing.T) {
+	graph := NewGraph()
+	baz := &Dep{Import: "github.com/foo/bar/baz"}
+	graph.Insert(baz)
+
+	if node := graph.SearchRepository("github.com/foo/bar"); node == nil || node.Dependency != baz {
+		t.Error("Expected search to find a dependency in the same repository")
+	}
+
+	if node := graph.SearchRepository("github.com/foo/bar/qux"); node == nil || node.Dependency != baz {
+		t.Error("Expected search to find a dependency in the same repository")
+	}
+
+	if graph.SearchRepository("github.com/foo/barista") != nil {
+		t.Error("Expected search to fail for another repository")
+	}
+}
+
+func TestPreOrderVisitIsSorted(t *testing.T) {
+	graph := NewGraph()
+	graph.Insert(&Dep{Import: "github.com/gorilla/mux"})
+	graph.Insert(&Dep{Import: "code.google.com/p/go.net"})
+	graph.Insert(&Dep{Import: "github.com/d2fn/gopack"})
+
+	keys := []string{}
+	graph.PreOrderVisit(func(n *Node, depth int) {
+		keys = append(keys, n.Key)
+	})
+
+	expected := "p go.net d2fn gopack gorilla mux"
+	if actual := strings.Join(keys, " "); actual != expected {
+		t.Errorf("Expected visit order to be %s, but was %s\n", expected, actual)
+	}
+}
+
+func TestWriteDependencyTree(t *testing.T) {
+	deps := &Dependencies{ImportGraph: NewGraph()}
+	deps.ImportGraph.Insert(&Dep{Import: "github.com/gorilla/mux", CheckoutSpec: "1.0rc2"})
+	deps.ImportGraph.Insert(&Dep{Import: "github.com/d2fn/gopack", CheckoutSpec: "master"})
+
+	var buf bytes.Buffer
+	deps.WriteDependencyTree(&buf)
+
+	expected := `+- d2fn
+  - github.com/d2fn/gopack @ master
++- gorilla
+  - github.com/gorilla/mux @ 1.0rc2
+`
+	if buf.String() != expected {
+		t.Errorf("Expected dependency tree to be\n%s\nbut was\n%s\n", expected, buf.String())
+	}
+}
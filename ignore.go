@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	IgnoreProp        = "ignore"
+	IgnoreImportsProp = "ignore-imports"
+)
+
+// Paths and imports left out of the source analysis.
+//
+// Patterns without a slash match the base name of any file or directory,
+// patterns with a slash match the path relative to the project root.
+// Both accept path.Match wildcards, and a trailing "/..." matches everything below.
+type Ignore struct {
+	Paths   []string
+	Imports []string
+}
+
+// Directories the go tool skips as well.
+func ignoredByDefault(name string) bool {
+	return name == "testdata" || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".")
+}
+
+func (i *Ignore) IgnorePath(relPath string) bool {
+	if i == nil {
+		return false
+	}
+	for _, pattern := range i.Paths {
+		if strings.Contains(pattern, "/") {
+			if matchPattern(pattern, relPath) {
+				return true
+			}
+		} else if matchPattern(pattern, path.Base(relPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Ignore) IgnoreImport(importPath string) bool {
+	if i == nil {
+		return false
+	}
+	for _, pattern := range i.Imports {
+		if matchPattern(pattern, importPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/...") {
+		prefix := strings.TrimSuffix(pattern, "/...")
+		if strings.HasPrefix(name, prefix+"/") {
+			return true
+		}
+		pattern = prefix
+	}
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
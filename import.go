@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	commitHash    = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+	exactVersion  = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*([-+][0-9A-Za-z.-]+)?$`)
+	pseudoVersion = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+-(.+\.)?[0-9]{14}-([0-9a-f]{12})(\+incompatible)?$`)
+	majorSuffix   = regexp.MustCompile(`/v[2-9][0-9]*$`)
+)
+
+// Manifests of other tools that gp import reads, in order of preference:
+// lock files pin exact revisions so they go before the manifests they lock.
+var manifestReaders = []struct {
+	Path string
+	Read func(data []byte) (*ManifestImport, error)
+}{
+	{"Godeps/Godeps.json", readGodeps},
+	{"glide.lock", readGlideLock},
+	{"glide.yaml", readGlideYaml},
+	{"Gopkg.lock", readGopkgLock},
+	{"Gopkg.toml", readGopkgToml},
+	{"vendor/vendor.json", readVendorJson},
+	{"go.mod", readGoMod},
+}
+
+// The dependencies read from the manifest of another tool.
+type ManifestImport struct {
+	Deps []*Dep
+	// dependencies that couldn't be mapped and why
+	Skipped []string
+}
+
+func newManifestImport() *ManifestImport {
+	return &ManifestImport{Deps: []*Dep{}, Skipped: []string{}}
+}
+
+// Add a dependency on the repository providing importPath, checked out at spec.
+// Packages of the same repository are merged into one dependency.
+func (m *ManifestImport) add(importPath, group string, flag uint8, spec string) *Dep {
+	if importPath == "" {
+		return nil
+	}
+	root := RepoRoot(importPath)
+	for _, dep := range m.Deps {
+		if root == dep.Import || strings.HasPrefix(root, dep.Import+"/") {
+			if dep.CheckoutFlag != flag || dep.CheckoutSpec != spec {
+				m.skip(importPath, "pinned at %s while %s is pinned at %s", spec, dep.Import, dep.CheckoutSpec)
+			}
+			return dep
+		}
+	}
+
+	d := NewDependency(root)
+	d.checkout(flag, spec)
+	d.Group = group
+	m.Deps = append(m.Deps, d)
+	return d
+}
+
+func (m *ManifestImport) skip(importPath, reason string, args ...interface{}) {
+	m.Skipped = append(m.Skipped, fmt.Sprintf("%s: %s", importPath, fmt.Sprintf(reason, args...)))
+}
+
+// Map a version of a manifest to a checkout: commit hashes to commits,
+// exact versions to tags and anything else to branches.
+// Version ranges can't be pinned.
+func (m *ManifestImport) addVersion(importPath, group, version string) *Dep {
+	switch {
+	case version == "":
+		return m.add(importPath, group, 0, "")
+	case commitHash.MatchString(version):
+		return m.add(importPath, group, CommitFlag, version)
+	case exactVersion.MatchString(version):
+		return m.add(importPath, group, TagFlag, version)
+	case strings.ContainsAny(version, "^~<>=*|, "):
+		m.skip(importPath, "version range %s can't be pinned", version)
+		return nil
+	}
+	return m.add(importPath, group, BranchFlag, version)
+}
+
+func (m *ManifestImport) setSource(d *Dep, source string) {
+	if d != nil && source != "" {
+		d.Source = source
+	}
+}
+
+// Godeps/Godeps.json, revisions of every package.
+func readGodeps(data []byte) (*ManifestImport, error) {
+	var godeps struct {
+		Deps []struct {
+			ImportPath string
+			Rev        string
+		}
+	}
+	if err := json.Unmarshal(data, &godeps); err != nil {
+		return nil, err
+	}
+
+	m := newManifestImport()
+	for _, dep := range godeps.Deps {
+		m.add(dep.ImportPath, "", CommitFlag, dep.Rev)
+	}
+	return m, nil
+}
+
+// vendor/vendor.json of govendor, revisions of every package.
+func readVendorJson(data []byte) (*ManifestImport, error) {
+	var vendor struct {
+		Package []struct {
+			Path     string
+			Revision string
+			Origin   string
+		}
+	}
+	if err := json.Unmarshal(data, &vendor); err != nil {
+		return nil, err
+	}
+
+	m := newManifestImport()
+	for _, pkg := range vendor.Package {
+		if pkg.Origin != "" && pkg.Origin != pkg.Path {
+			m.skip(pkg.Path, "copied from %s", pkg.Origin)
+			continue
+		}
+		m.add(pkg.Path, "", CommitFlag, pkg.Revision)
+	}
+	return m, nil
+}
+
+// glide.lock, revisions of the imports and test imports.
+func readGlideLock(data []byte) (*ManifestImport, error) {
+	m := newManifestImport()
+	for _, section := range []string{"imports", "testImports"} {
+		group := ""
+		if section == "testImports" {
+			group = TestGroup
+		}
+		for _, item := range yamlList(data, section) {
+			d := m.add(item["name"], group, CommitFlag, item["version"])
+			m.setSource(d, item["repo"])
+		}
+	}
+	return m, nil
+}
+
+// glide.yaml, versions of the imports and test imports.
+func readGlideYaml(data []byte) (*ManifestImport, error) {
+	m := newManifestImport()
+	for _, section := range []string{"import", "testImport"} {
+		group := ""
+		if section == "testImport" {
+			group = TestGroup
+		}
+		for _, item := range yamlList(data, section) {
+			d := m.addVersion(item["package"], group, item["version"])
+			m.setSource(d, item["repo"])
+		}
+	}
+	return m, nil
+}
+
+// Gopkg.lock of dep, revisions of the projects.
+func readGopkgLock(data []byte) (*ManifestImport, error) {
+	t, err := toml.Load(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	m := newManifestImport()
+	for _, project := range tomlTables(t, "projects") {
+		name, _ := getString(project, "name")
+		revision, _ := getString(project, "revision")
+		source, _ := getString(project, "source")
+		m.setSource(m.add(name, "", CommitFlag, revision), source)
+	}
+	return m, nil
+}
+
+// Gopkg.toml of dep, constraints and overrides.
+// Versions are ranges unless they start with "=".
+func readGopkgToml(data []byte) (*ManifestImport, error) {
+	t, err := toml.Load(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	m := newManifestImport()
+	for _, name := range []string{"constraint", "override"} {
+		for _, project := range tomlTables(t, name) {
+			importPath, _ := getString(project, "name")
+			revision, _ := getString(project, "revision")
+			branch, _ := getString(project, "branch")
+			version, _ := getString(project, "version")
+			source, _ := getString(project, "source")
+
+			var d *Dep
+			switch {
+			case revision != "":
+				d = m.add(importPath, "", CommitFlag, revision)
+			case branch != "":
+				d = m.add(importPath, "", BranchFlag, branch)
+			case strings.HasPrefix(version, "="):
+				d = m.add(importPath, "", TagFlag, strings.TrimSpace(strings.TrimPrefix(version, "=")))
+			case version != "":
+				m.skip(importPath, "version range %s can't be pinned", version)
+			default:
+				d = m.add(importPath, "", 0, "")
+			}
+			m.setSource(d, source)
+		}
+	}
+	return m, nil
+}
+
+// go.mod, required module versions.
+// Replaced modules are skipped since gopack fetches every import from its path.
+func readGoMod(data []byte) (*ManifestImport, error) {
+	m := newManifestImport()
+	replaced := make(map[string]bool)
+	block := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == ")" {
+			block = ""
+			continue
+		}
+
+		directive := block
+		if block == "" {
+			if len(fields) == 2 && fields[1] == "(" {
+				block = fields[0]
+				continue
+			}
+			directive, fields = fields[0], fields[1:]
+		}
+
+		switch directive {
+		case "require":
+			if len(fields) == 2 {
+				m.addModule(fields[0], fields[1])
+			}
+		case "replace":
+			for i, field := range fields {
+				if field == "=>" && i > 0 {
+					replaced[fields[0]] = true
+					m.skip(fields[0], "replaced by %s", strings.Join(fields[i+1:], " "))
+				}
+			}
+		}
+	}
+
+	deps := []*Dep{}
+	for _, dep := range m.Deps {
+		if !replaced[dep.Import] {
+			deps = append(deps, dep)
+		}
+	}
+	m.Deps = deps
+	return m, scanner.Err()
+}
+
+// Pseudo-versions are pinned to their commit and releases to their tag.
+func (m *ManifestImport) addModule(module, version string) {
+	if majorSuffix.MatchString(module) {
+		m.skip(module, "major version suffixes need modules")
+		return
+	}
+	if match := pseudoVersion.FindStringSubmatch(version); match != nil {
+		m.add(module, "", CommitFlag, match[2])
+		return
+	}
+	m.add(module, "", TagFlag, strings.TrimSuffix(version, "+incompatible"))
+}
+
+// The items of a top level list in a YAML document, as maps of their scalar values.
+// Only the block style written by glide is supported.
+func yamlList(data []byte, key string) []map[string]string {
+	items := []map[string]string{}
+	var item map[string]string
+	inList := false
+	itemIndent := -1
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+
+		if indent == 0 && !strings.HasPrefix(trimmed, "- ") {
+			inList = strings.TrimSpace(strings.TrimSuffix(trimmed, ":")) == key
+			item = nil
+			itemIndent = -1
+			continue
+		}
+		if !inList {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") && (itemIndent == -1 || indent == itemIndent) {
+			itemIndent = indent
+			item = make(map[string]string)
+			items = append(items, item)
+			trimmed = strings.TrimPrefix(trimmed, "- ")
+			indent += 2
+		} else if item == nil || indent != itemIndent+2 {
+			// nested lists like subpackages
+			continue
+		}
+
+		if i := strings.Index(trimmed, ":"); i > 0 {
+			value := strings.TrimSpace(trimmed[i+1:])
+			item[trimmed[:i]] = strings.Trim(value, `"'`)
+		}
+	}
+	return items
+}
+
+// The array of tables named key.
+func tomlTables(t *toml.TomlTree, key string) []*toml.TomlTree {
+	if tables, ok := t.Get(key).([]*toml.TomlTree); ok {
+		return tables
+	}
+	return []*toml.TomlTree{}
+}
+
+// The dependencies as gopack.config tables, followed by the skipped ones as comments.
+func (m *ManifestImport) Config() string {
+	var w bytes.Buffer
+	keys := make(map[string]bool)
+	for _, dep := range m.Deps {
+		key := tableKey(dep.Import, keys)
+		keys[key] = true
+
+		table := "deps"
+		if dep.Group == TestGroup {
+			table = "test-deps"
+		}
+		fmt.Fprintf(&w, "[%s.%s]\n", table, key)
+		fmt.Fprintf(&w, "import = %q\n", dep.Import)
+		if dep.Source != "" {
+			fmt.Fprintf(&w, "source = %q\n", dep.Source)
+		}
+		if dep.CheckoutType() != "" {
+			fmt.Fprintf(&w, "%s = %q\n", dep.CheckoutType(), dep.CheckoutSpec)
+		}
+		fmt.Fprintln(&w)
+	}
+
+	for _, s := range m.Skipped {
+		fmt.Fprintf(&w, "# skipped %s\n", s)
+	}
+	return w.String()
+}
+
+// gp import [manifest]
+func importManifest(args []string) {
+	for _, reader := range manifestReaders {
+		if len(args) > 0 && args[0] != reader.Path {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(reader.Path)
+		if os.IsNotExist(err) && len(args) == 0 {
+			continue
+		}
+		if err != nil {
+			fail(err)
+		}
+
+		m, err := reader.Read(data)
+		if err != nil {
+			failf("%s - %s\n", reader.Path, err)
+		}
+		fmt.Printf("# imported from %s\n\n", reader.Path)
+		fmt.Print(m.Config())
+		return
+	}
+
+	paths := []string{}
+	for _, reader := range manifestReaders {
+		paths = append(paths, reader.Path)
+	}
+	failf("no manifest to import, expected one of %s\n", strings.Join(paths, ", "))
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"testing"
+)
+
+func checkImportedDeps(t *testing.T, m *ManifestImport, expected ...string) {
+	if len(m.Deps) != len(expected) {
+		t.Fatalf("Expected %d dependencies, but were %d:\n%s", len(expected), len(m.Deps), m.Config())
+	}
+	for i, dep := range m.Deps {
+		if dep.String() != expected[i] {
+			t.Errorf("Expected %q, but was %q", expected[i], dep.String())
+		}
+	}
+}
+
+func checkSkipped(t *testing.T, m *ManifestImport, expected ...string) {
+	if len(m.Skipped) != len(expected) {
+		t.Fatalf("Expected %d skipped dependencies, but were %v", len(expected), m.Skipped)
+	}
+	for i, s := range m.Skipped {
+		if s != expected[i] {
+			t.Errorf("Expected %q, but was %q", expected[i], s)
+		}
+	}
+}
+
+func TestReadGodeps(t *testing.T) {
+	setupTestPwd()
+	m, err := readGodeps([]byte(`{
+	"ImportPath": "github.com/acme/api",
+	"Deps": [
+		{"ImportPath": "github.com/gorilla/mux", "Comment": "v1.1", "Rev": "9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e"},
+		{"ImportPath": "github.com/gorilla/mux/route", "Rev": "9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e"},
+		{"ImportPath": "github.com/pelletier/go-toml", "Rev": "23d36c08ab90f4957ae8e7d781907c368f5454dd"}
+	]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedDeps(t, m,
+		"import = github.com/gorilla/mux, commit = 9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e",
+		"import = github.com/pelletier/go-toml, commit = 23d36c08ab90f4957ae8e7d781907c368f5454dd")
+	checkSkipped(t, m)
+}
+
+func TestReadVendorJson(t *testing.T) {
+	setupTestPwd()
+	m, err := readVendorJson([]byte(`{
+	"package": [
+		{"path": "github.com/gorilla/mux", "revision": "9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e"},
+		{"path": "github.com/gorilla/context", "origin": "github.com/gorilla/mux/vendor/github.com/gorilla/context", "revision": "08b5f424b9271eedf6f9f0ce86cb9396ed337a42"}
+	]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedDeps(t, m, "import = github.com/gorilla/mux, commit = 9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e")
+	checkSkipped(t, m, "github.com/gorilla/context: copied from github.com/gorilla/mux/vendor/github.com/gorilla/context")
+}
+
+func TestReadGlide(t *testing.T) {
+	setupTestPwd()
+	m, err := readGlideYaml([]byte(`package: github.com/acme/api
+import:
+- package: github.com/gorilla/mux
+  version: v1.1
+  subpackages:
+  - route
+- package: github.com/pelletier/go-toml
+  version: ^1.0.0
+- package: golang.org/x/net
+  repo: https://git.example.com/mirror/net.git
+  version: master
+testImport:
+- package: github.com/stretchr/testify
+  version: 69483b4bd14f5845b5a1e55bca19e954e827f1d0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedDeps(t, m,
+		"import = github.com/gorilla/mux, tag = v1.1",
+		"import = golang.org/x/net, branch = master",
+		"import = github.com/stretchr/testify, commit = 69483b4bd14f5845b5a1e55bca19e954e827f1d0")
+	checkSkipped(t, m, "github.com/pelletier/go-toml: version range ^1.0.0 can't be pinned")
+
+	if m.Deps[1].Source != "https://git.example.com/mirror/net.git" || m.Deps[2].Group != TestGroup {
+		t.Errorf("Expected the source and group to be imported")
+	}
+
+	m, err = readGlideLock([]byte(`hash: 2d3b5c9c0a3f
+updated: 2016-08-02T10:00:00Z
+imports:
+- name: github.com/gorilla/mux
+  version: 9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e
+  subpackages:
+  - route
+testImports: []
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImportedDeps(t, m, "import = github.com/gorilla/mux, commit = 9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e")
+}
+
+func TestReadGopkg(t *testing.T) {
+	setupTestPwd()
+	m, err := readGopkgToml([]byte(`
+[[constraint]]
+  name = "github.com/gorilla/mux"
+  version = "=1.6.0"
+
+[[constraint]]
+  name = "github.com/pelletier/go-toml"
+  version = "1.0.0"
+
+[[override]]
+  name = "golang.org/x/net"
+  branch = "master"
+  source = "https://git.example.com/mirror/net.git"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedDeps(t, m,
+		"import = github.com/gorilla/mux, tag = 1.6.0",
+		"import = golang.org/x/net, branch = master")
+	checkSkipped(t, m, "github.com/pelletier/go-toml: version range 1.0.0 can't be pinned")
+
+	m, err = readGopkgLock([]byte(`
+[[projects]]
+  name = "github.com/gorilla/mux"
+  packages = [".", "route"]
+  revision = "9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e"
+  version = "v1.6.0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImportedDeps(t, m, "import = github.com/gorilla/mux, commit = 9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e")
+}
+
+func TestReadGoMod(t *testing.T) {
+	setupTestPwd()
+	m, err := readGoMod([]byte(`module github.com/acme/api
+
+go 1.12
+
+require (
+	github.com/gorilla/mux v1.6.2
+	github.com/go-redis/redis/v8 v8.11.0
+	golang.org/x/net v0.0.0-20190404232315-eb5bcb51f2a3 // indirect
+	github.com/pelletier/go-toml v1.2.0
+)
+
+require github.com/stretchr/testify v1.3.0+incompatible
+
+replace github.com/pelletier/go-toml => ../go-toml
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedDeps(t, m,
+		"import = github.com/gorilla/mux, tag = v1.6.2",
+		"import = golang.org/x/net, commit = eb5bcb51f2a3",
+		"import = github.com/stretchr/testify, tag = v1.3.0")
+	checkSkipped(t, m,
+		"github.com/go-redis/redis/v8: major version suffixes need modules",
+		"github.com/pelletier/go-toml: replaced by ../go-toml")
+}
+
+func TestManifestImportConfig(t *testing.T) {
+	m := newManifestImport()
+	m.setSource(m.add("github.com/gorilla/mux", "", TagFlag, "v1.1"), "https://git.example.com/mux.git")
+	m.add("github.com/stretchr/testify", TestGroup, CommitFlag, "69483b4bd14f5845b5a1e55bca19e954e827f1d0")
+	m.skip("github.com/pelletier/go-toml", "version range %s can't be pinned", "^1.0.0")
+
+	expected := `[deps.mux]
+import = "github.com/gorilla/mux"
+source = "https://git.example.com/mux.git"
+tag = "v1.1"
+
+[test-deps.testify]
+import = "github.com/stretchr/testify"
+commit = "69483b4bd14f5845b5a1e55bca19e954e827f1d0"
+
+# skipped github.com/pelletier/go-toml: version range ^1.0.0 can't be pinned
+`
+	if m.Config() != expected {
+		t.Errorf("Expected config:\n%s\nbut was:\n%s", expected, m.Config())
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const IncludeProp = "include"
+
+// Tables that included configs can share.
+var sharedTables = []string{"deps", "test-deps"}
+
+// A dependency table and the config file it comes from.
+type includedTable struct {
+	Tree   *toml.TomlTree
+	Source string
+}
+
+// Tables by name ("deps" or "test-deps") and key.
+type includedTables map[string]map[string]*includedTable
+
+func newIncludedTables() includedTables {
+	tables := includedTables{}
+	for _, name := range sharedTables {
+		tables[name] = make(map[string]*includedTable)
+	}
+	return tables
+}
+
+// Load the configs included by t, paths relative to dir.
+// Tables of a config override the ones of its includes,
+// and two includes defining the same table differently are an error.
+// chain holds the configs being included, to detect include cycles.
+func (c *Config) loadIncludes(t *toml.TomlTree, dir string, chain []string) (includedTables, error) {
+	tables := newIncludedTables()
+
+	includes, err := getStrings(t, IncludeProp)
+	if err != nil {
+		return nil, fmt.Errorf("%s - %s", chain[len(chain)-1], err)
+	}
+	for _, include := range includes {
+		path, err := filepath.Abs(filepath.Join(dir, include))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range chain {
+			if p == path {
+				return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), path)
+			}
+		}
+
+		included, err := toml.LoadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s - can't include %s: %s", chain[len(chain)-1], path, err)
+		}
+		if err := c.checkSchema(CheckConfig(included, path)); err != nil {
+			return nil, err
+		}
+		c.addInclude(path)
+
+		nested, err := c.loadIncludes(included, filepath.Dir(path), append(chain, path))
+		if err != nil {
+			return nil, err
+		}
+		err = nested.override(included, path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tables.merge(nested)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tables, nil
+}
+
+func (c *Config) addInclude(path string) {
+	for _, p := range c.Includes {
+		if p == path {
+			return
+		}
+	}
+	c.Includes = append(c.Includes, path)
+}
+
+// Replace the tables with the ones defined in t.
+func (tables includedTables) override(t *toml.TomlTree, source string) error {
+	for _, name := range sharedTables {
+		tree, err := getTable(t, name)
+		if err != nil {
+			return fmt.Errorf("%s - %s", source, err)
+		}
+		if tree == nil {
+			continue
+		}
+		for _, k := range tree.Keys() {
+			table, err := getTable(tree, k)
+			if err != nil {
+				return fmt.Errorf("%s - %s.%s", source, name, err)
+			}
+			tables[name][k] = &includedTable{table, source}
+		}
+	}
+	return nil
+}
+
+func (tables includedTables) merge(other includedTables) error {
+	for _, name := range sharedTables {
+		for k, table := range other[name] {
+			existing, found := tables[name][k]
+			if found && tableSignature(existing.Tree) != tableSignature(table.Tree) {
+				return fmt.Errorf("[%s.%s] is defined differently in %s and %s", name, k, existing.Source, table.Source)
+			}
+			tables[name][k] = table
+		}
+	}
+	return nil
+}
+
+// Add the included tables missing from the tree of the config.
+func (tables includedTables) mergeInto(tree *toml.TomlTree, name string) *toml.TomlTree {
+	if len(tables[name]) == 0 {
+		return tree
+	}
+	if tree == nil {
+		tree, _ = toml.Load("")
+	}
+	for k, table := range tables[name] {
+		if tree.Get(k) == nil {
+			tree.Set(k, table.Tree)
+		}
+	}
+	return tree
+}
+
+func tableSignature(t *toml.TomlTree) string {
+	keys := t.Keys()
+	sort.Strings(keys)
+	values := []string{}
+	for _, k := range keys {
+		values = append(values, fmt.Sprintf("%s=%v", k, t.Get(k)))
+	}
+	return strings.Join(values, "\n")
+}
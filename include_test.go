@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/pelletier/go-toml"
+	"path"
+	"strings"
+	"testing"
+)
+
+func setupIncludes() {
+	setupTestPwd()
+
+	createPath(path.Join(pwd, "common"))
+	createFixtureConfig(path.Join(pwd, "common"), `
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  branch = "master"
+[deps.toml]
+  import = "github.com/pelletier/go-toml"
+  commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+`)
+
+	createPath(path.Join(pwd, "other"))
+	createFixtureConfig(path.Join(pwd, "other"), `
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "1.0rc2"
+`)
+}
+
+func TestConfigIncludes(t *testing.T) {
+	setupIncludes()
+
+	service := path.Join(pwd, "service")
+	createPath(service)
+	createFixtureConfig(service, `
+include = ["../common/gopack.config"]
+
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "1.0rc2"
+`)
+
+	config := NewConfig(service)
+
+	if len(config.Includes) != 1 || config.Includes[0] != path.Join(pwd, "common", "gopack.config") {
+		t.Errorf("Expected the included config to be tracked, but were %v", config.Includes)
+	}
+
+	deps, _ := config.DependencyModel(NewGraph())
+	if len(deps.DepList) != 2 {
+		t.Fatalf("Expected included dependencies to be merged, but loaded %d", len(deps.DepList))
+	}
+
+	for _, dep := range deps.DepList {
+		if dep.Import == "github.com/gorilla/mux" && dep.CheckoutSpec != "1.0rc2" {
+			t.Errorf("Expected local tables to override included ones, but mux was at %s", dep.CheckoutSpec)
+		}
+	}
+
+	checksum := config.checksum()
+	createFixtureConfig(path.Join(pwd, "common"), `
+[deps.toml]
+  import = "github.com/pelletier/go-toml"
+  branch = "master"
+`)
+	config.Checksum = nil
+	if string(config.checksum()) == string(checksum) {
+		t.Errorf("Expected the checksum to cover included configs")
+	}
+}
+
+func TestConfigIncludesDisagree(t *testing.T) {
+	setupIncludes()
+
+	tree, err := toml.Load(`include = ["common/gopack.config", "other/gopack.config"]`)
+	check(err)
+
+	config := &Config{Path: path.Join(pwd, "gopack.config")}
+	_, err = config.loadIncludes(tree, pwd, []string{config.Path})
+	if err == nil || !strings.Contains(err.Error(), "[deps.mux] is defined differently in") {
+		t.Errorf("Expected includes defining mux differently to fail, but error was %v", err)
+	}
+}
+
+func TestConfigIncludesCycle(t *testing.T) {
+	setupTestPwd()
+
+	createFixtureConfig(pwd, `include = ["gopack.config"]`)
+
+	tree, err := toml.LoadFile(path.Join(pwd, "gopack.config"))
+	check(err)
+
+	config := &Config{Path: path.Join(pwd, "gopack.config")}
+	_, err = config.loadIncludes(tree, pwd, []string{config.Path})
+	if err == nil || !strings.HasPrefix(err.Error(), "include cycle") {
+		t.Errorf("Expected include cycle to fail, but error was %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const UnknownLicense = "unknown"
+
+// Licenses told apart by the classifier, most specific first.
+// The first phrase names the license.
+var licenseClassifiers = []struct {
+	License string
+	// phrases of the normalized license text, all of them must be found
+	Phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"LGPL-2.0", []string{"gnu library general public license"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "version 2.0"}},
+	{"MPL-1.1", []string{"mozilla public license", "version 1.1"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "endorse or promote products derived from this software"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+}
+
+var (
+	licenseFile = regexp.MustCompile(`(?i)^(LICEN[CS]E|COPYING|UNLICENSE)([.-].*)?$`)
+	whitespace  = regexp.MustCompile(`[\s*#/]+`)
+)
+
+// The licenses of a dependency and the files they were found in.
+type DependencyLicense struct {
+	Import   string   `json:"import"`
+	Licenses []string `json:"licenses"`
+	Files    []string `json:"files"`
+}
+
+// Classify a license text, UnknownLicense if it isn't one of the known licenses.
+// Licenses mention other licenses, the GPL mentions the LGPL for instance,
+// so the one named first wins.
+func ClassifyLicense(text string) string {
+	normalized := strings.TrimSpace(whitespace.ReplaceAllString(strings.ToLower(text), " "))
+	license, first := UnknownLicense, -1
+	for _, c := range licenseClassifiers {
+		found := true
+		for _, phrase := range c.Phrases {
+			if !strings.Contains(normalized, phrase) {
+				found = false
+				break
+			}
+		}
+		if i := strings.Index(normalized, c.Phrases[0]); found && (first == -1 || i < first) {
+			license, first = c.License, i
+		}
+	}
+	return license
+}
+
+// Detect the licenses of the dependency from the license files at the root of its repository.
+// A dependency without license files has an unknown license.
+func DetectLicenses(dep *Dep) (*DependencyLicense, error) {
+	dir := NewDependency(RepoRoot(dep.Import)).Src()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &DependencyLicense{Import: dep.Import, Licenses: []string{}, Files: []string{}}
+	found := make(map[string]bool)
+	for _, info := range infos {
+		if info.IsDir() || !licenseFile.MatchString(info.Name()) || strings.HasSuffix(info.Name(), ".go") {
+			continue
+		}
+		dat, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		l.Files = append(l.Files, info.Name())
+		if license := ClassifyLicense(string(dat)); !found[license] {
+			found[license] = true
+			l.Licenses = append(l.Licenses, license)
+		}
+	}
+
+	if len(l.Licenses) == 0 {
+		l.Licenses = append(l.Licenses, UnknownLicense)
+	}
+	sort.Strings(l.Licenses)
+	return l, nil
+}
+
+// The licenses of the dependencies, sorted by import path.
+func DetectAllLicenses(deps []*Dep) ([]*DependencyLicense, error) {
+	sorted := make([]*Dep, len(deps))
+	copy(sorted, deps)
+	sort.Sort(depsByImport(sorted))
+
+	licenses := []*DependencyLicense{}
+	for _, dep := range sorted {
+		l, err := DetectLicenses(dep)
+		if err != nil {
+			return nil, err
+		}
+		licenses = append(licenses, l)
+	}
+	return licenses, nil
+}
+
+// gp licenses [--json]
+func printLicenses(config *Config, p *ProjectStats, args []string) {
+	licenses, err := DetectAllLicenses(vendoredDependencies(config))
+	if err != nil {
+		fail(err)
+	}
+
+	if hasFlag(args, "--json") {
+		out, err := json.MarshalIndent(licenses, "", "  ")
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "IMPORT\tLICENSE\tFILES")
+	for _, l := range licenses {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Import, strings.Join(l.Licenses, ", "), strings.Join(l.Files, ", "))
+	}
+	w.Flush()
+}
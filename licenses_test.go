@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/pelletier/go-toml"
+	"testing"
+)
+
+const (
+	mitLicense = `The MIT License (MIT)
+
+Copyright (c) 2013 Mitchell Hashimoto
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction.`
+
+	bsd3License = `Copyright (c) 2012 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.`
+
+	gpl2License = `		    GNU GENERAL PUBLIC LICENSE
+		       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.
+ (Some other Free Software Foundation software is covered by
+the GNU Library General Public License instead.)`
+
+	lgpl21License = `                  GNU LESSER GENERAL PUBLIC LICENSE
+                       Version 2.1, February 1999
+
+  This license, the Lesser General Public License, applies to some
+specially designated software packages.  [This is the first released version of the
+Lesser GPL.  It also counts as the successor of the GNU Library Public
+License, version 2, hence the version number 2.1.]  See the GNU General Public License.`
+)
+
+func TestClassifyLicense(t *testing.T) {
+	for text, expected := range map[string]string{
+		mitLicense:    "MIT",
+		bsd3License:   "BSD-3-Clause",
+		gpl2License:   "GPL-2.0",
+		lgpl21License: "LGPL-2.1",
+		"Redistribution and use in source and binary forms, with or without modification, are permitted":        "BSD-2-Clause",
+		"                                 Apache License\n                           Version 2.0, January 2004": "Apache-2.0",
+		"All rights reserved.": UnknownLicense,
+	} {
+		if license := ClassifyLicense(text); license != expected {
+			t.Errorf("Expected %s, but was %s for:\n%s", expected, license, text)
+		}
+	}
+}
+
+func TestDetectLicenses(t *testing.T) {
+	setupTestPwd()
+	dep := createScmDep(".git", "github.com/acme/lib")
+	createSourceFixture(dep.Src(), "LICENSE", mitLicense)
+	createSourceFixture(dep.Src(), "COPYING.LESSER", lgpl21License)
+	createSourceFixture(dep.Src(), "license.go", "package lib\n")
+	unlicensed := createScmDep(".git", "github.com/acme/other")
+
+	licenses, err := DetectAllLicenses([]*Dep{unlicensed, dep})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(licenses) != 2 || licenses[0].Import != "github.com/acme/lib" {
+		t.Fatalf("Expected the licenses of every dependency sorted by import, but were %v", licenses)
+	}
+	l := licenses[0]
+	if len(l.Licenses) != 2 || l.Licenses[0] != "LGPL-2.1" || l.Licenses[1] != "MIT" {
+		t.Errorf("Expected the license of each file, but were %v", l.Licenses)
+	}
+	if len(l.Files) != 2 || l.Files[0] != "COPYING.LESSER" || l.Files[1] != "LICENSE" {
+		t.Errorf("Expected the license files, but were %v", l.Files)
+	}
+	if len(licenses[1].Licenses) != 1 || licenses[1].Licenses[0] != UnknownLicense {
+		t.Errorf("Expected dependencies without license files to have an unknown license")
+	}
+
+	policy := &Policy{DenyLicenses: []string{"LGPL", "GPL-3.0"}}
+	errors := policy.ValidateLicenses(licenses)
+	if len(errors) != 1 || errors[0].Kind != DeniedLicense || errors[0].Path != "github.com/acme/lib" {
+		t.Fatalf("Expected the LGPL dependency to be denied, but were %v", errors)
+	}
+	expected := "github.com/acme/lib is licensed under LGPL-2.1, denied by the policy in gopack.config (COPYING.LESSER, LICENSE)\n"
+	if errors[0].Message != expected {
+		t.Errorf("Expected message %q, but was %q", expected, errors[0].Message)
+	}
+}
+
+func TestDeniesLicense(t *testing.T) {
+	policy := &Policy{DenyLicenses: []string{"GPL", "MPL-2.0"}}
+	for license, denied := range map[string]bool{
+		"GPL-2.0":  true,
+		"GPL-3.0":  true,
+		"LGPL-2.1": false,
+		"AGPL-3.0": false,
+		"MPL-2.0":  true,
+		"MPL-1.1":  false,
+	} {
+		if policy.DeniesLicense(license) != denied {
+			t.Errorf("Expected %s to be denied: %v", license, denied)
+		}
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	tree, _ := toml.Load(`[policy]
+deny-licenses = ["GPL", "WTFPL"]
+allow = ["MIT"]
+allow-hosts = ["github.com", "https://git.acme.com"]
+deny-imports = "github.com/b/lib"
+require-immutable = "yes"
+`)
+
+	expected := []string{
+		"gopack.config:2:1: [policy] deny-licenses unknown license WTFPL",
+		"gopack.config:3:1: [policy] unknown key allow",
+		"gopack.config:4:1: [policy] allow-hosts https://git.acme.com isn't a host name",
+		"gopack.config:5:1: [policy] deny-imports must be a list of strings",
+		"gopack.config:6:1: [policy] require-immutable must be true or false",
+	}
+	problems := CheckConfig(tree, "gopack.config")
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, but were:\n%s", len(expected), problems.Error())
+	}
+	for i, p := range problems {
+		if p.Error() != expected[i] {
+			t.Errorf("Expected %q, but was %q", expected[i], p.Error())
+		}
+	}
+}
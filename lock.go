@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+const RevisionProp = "revision"
+
+// The revisions the dependencies resolved to, recorded in gopack.lock
+// so branches can be pinned and moved tags noticed.
+type Lock struct {
+	Path string
+	// locked dependencies by import path
+	Deps map[string]*LockedDep
+}
+
+type LockedDep struct {
+	Import string
+	// checkout type and spec in the config when the revision was recorded
+	Checkout string
+	Spec     string
+	Revision string
+}
+
+// Load the lock at path, empty when there's none.
+func LoadLock(path string) (*Lock, error) {
+	l := &Lock{Path: path, Deps: make(map[string]*LockedDep)}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return l, nil
+	}
+
+	t, err := toml.LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s - %s", path, err)
+	}
+	depsTree, err := getTable(t, "deps")
+	if err != nil || depsTree == nil {
+		return l, err
+	}
+
+	for _, key := range depsTree.Keys() {
+		depTree, err := getTable(depsTree, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s - %s", path, err)
+		}
+		locked := &LockedDep{}
+		if locked.Import, err = getString(depTree, ImportProp); err != nil {
+			return nil, fmt.Errorf("%s - %s", path, err)
+		}
+		if locked.Revision, err = getString(depTree, RevisionProp); err != nil {
+			return nil, fmt.Errorf("%s - %s", path, err)
+		}
+		for _, checkout := range []string{BranchProp, CommitProp, TagProp} {
+			if spec, _ := getString(depTree, checkout); spec != "" {
+				locked.Checkout, locked.Spec = checkout, spec
+			}
+		}
+		if locked.Import != "" && locked.Revision != "" {
+			l.Deps[locked.Import] = locked
+		}
+	}
+	return l, nil
+}
+
+// The revision recorded for the dependency, empty when there's none or
+// when it was recorded for another checkout.
+func (l *Lock) Revision(dep *Dep) string {
+	if l == nil {
+		return ""
+	}
+	locked, found := l.Deps[dep.Import]
+	if !found || locked.Checkout != dep.CheckoutType() || locked.Spec != dep.CheckoutSpec {
+		return ""
+	}
+	return locked.Revision
+}
+
+// Record the revisions of the dependencies, replacing the previous ones.
+// Tags resolving to another commit than the one recorded are reported,
+// and keep their recorded commit until their entry is removed.
+// The skipped dependencies keep their entry as long as their checkout is the same,
+// so the lock doesn't depend on the command that ran last.
+func (l *Lock) Update(deps, skipped []*Dep, revision func(dep *Dep) (string, error)) []*ProjectError {
+	errors := []*ProjectError{}
+	locked := make(map[string]*LockedDep)
+
+	sorted := make([]*Dep, len(deps))
+	copy(sorted, deps)
+	sort.Sort(depsByImport(sorted))
+
+	for _, dep := range sorted {
+		rev, err := revision(dep)
+		if err != nil || rev == "" {
+			// local checkouts and the like have no revision to record
+			continue
+		}
+		if recorded := l.Revision(dep); dep.CheckoutFlag == TagFlag && recorded != "" && recorded != rev {
+			errors = append(errors, MovedTagError(dep, recorded, rev))
+			rev = recorded
+		}
+		locked[dep.Import] = &LockedDep{dep.Import, dep.CheckoutType(), dep.CheckoutSpec, rev}
+	}
+
+	for _, dep := range skipped {
+		if _, found := locked[dep.Import]; !found && l.Revision(dep) != "" {
+			locked[dep.Import] = l.Deps[dep.Import]
+		}
+	}
+
+	l.Deps = locked
+	return errors
+}
+
+func (l *Lock) Write(w io.Writer) {
+	imports := []string{}
+	for importPath := range l.Deps {
+		imports = append(imports, importPath)
+	}
+	sort.Strings(imports)
+
+	fmt.Fprintln(w, "# generated by gopack, commit it to pin the revision of every dependency")
+	keys := make(map[string]bool)
+	for _, importPath := range imports {
+		locked := l.Deps[importPath]
+		key := tableKey(importPath, keys)
+		keys[key] = true
+		fmt.Fprintf(w, "\n[deps.%s]\n", key)
+		fmt.Fprintf(w, "import = %q\n", locked.Import)
+		if locked.Checkout != "" {
+			fmt.Fprintf(w, "%s = %q\n", locked.Checkout, locked.Spec)
+		}
+		fmt.Fprintf(w, "revision = %q\n", locked.Revision)
+	}
+}
+
+// Write the lock to its path, unless it didn't change.
+func (l *Lock) WriteFile() error {
+	var b bytes.Buffer
+	l.Write(&b)
+	if current, err := ioutil.ReadFile(l.Path); err == nil && bytes.Equal(current, b.Bytes()) {
+		return nil
+	}
+	return ioutil.WriteFile(l.Path, b.Bytes(), 0644)
+}
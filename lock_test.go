@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func fakeRevisions(revisions map[string]string) func(dep *Dep) (string, error) {
+	return func(dep *Dep) (string, error) {
+		if rev, found := revisions[dep.Import]; found {
+			return rev, nil
+		}
+		return "", fmt.Errorf("no revision for %s", dep.Import)
+	}
+}
+
+func TestLockRoundTrip(t *testing.T) {
+	setupTestPwd()
+	lock, err := LoadLock(path.Join(pwd, GopackLock))
+	if err != nil || len(lock.Deps) != 0 {
+		t.Fatalf("Expected an empty lock without gopack.lock, but was %v, %v", lock, err)
+	}
+
+	branch := NewDependency("github.com/a/lib")
+	branch.checkout(BranchFlag, "master")
+	tag := NewDependency("github.com/b/lib")
+	tag.checkout(TagFlag, "v1.0.0")
+	unpinned := NewDependency("github.com/c/lib")
+	local := NewDependency("github.com/d/lib")
+
+	errors := lock.Update([]*Dep{unpinned, tag, branch, local}, nil, fakeRevisions(map[string]string{
+		"github.com/a/lib": "a1",
+		"github.com/b/lib": "b1",
+		"github.com/c/lib": "c1",
+	}))
+	if len(errors) != 0 {
+		t.Errorf("Expected no errors, but was %v", errors)
+	}
+	if err := lock.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadFile(lock.Path)
+	expected := `# generated by gopack, commit it to pin the revision of every dependency
+
+[deps.lib]
+import = "github.com/a/lib"
+branch = "master"
+revision = "a1"
+
+[deps.lib-2]
+import = "github.com/b/lib"
+tag = "v1.0.0"
+revision = "b1"
+
+[deps.lib-3]
+import = "github.com/c/lib"
+revision = "c1"
+`
+	if string(content) != expected {
+		t.Errorf("Expected gopack.lock:\n%s\nbut was:\n%s", expected, content)
+	}
+
+	lock, err = LoadLock(lock.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev := lock.Revision(branch); rev != "a1" {
+		t.Errorf("Expected the branch to be locked at a1, but was %q", rev)
+	}
+	if rev := lock.Revision(unpinned); rev != "c1" {
+		t.Errorf("Expected the unpinned dependency to be locked at c1, but was %q", rev)
+	}
+	develop := NewDependency("github.com/a/lib")
+	develop.checkout(BranchFlag, "develop")
+	if rev := lock.Revision(develop); rev != "" {
+		t.Errorf("Expected another branch not to be locked, but was %q", rev)
+	}
+}
+
+func TestLockMovedTag(t *testing.T) {
+	setupTestPwd()
+	lock, _ := LoadLock(path.Join(pwd, GopackLock))
+	tag := NewDependency("github.com/b/lib")
+	tag.checkout(TagFlag, "v1.0.0")
+	lock.Update([]*Dep{tag}, nil, fakeRevisions(map[string]string{"github.com/b/lib": "b1"}))
+
+	errors := lock.Update([]*Dep{tag}, nil, fakeRevisions(map[string]string{"github.com/b/lib": "b2"}))
+	if len(errors) != 1 || errors[0].Kind != MovedTag {
+		t.Fatalf("Expected a moved-tag error, but was %v", errors)
+	}
+	expected := "github.com/b/lib tag v1.0.0 resolves to b2 but gopack.lock recorded b1, the tag was moved\n"
+	if errors[0].Message != expected {
+		t.Errorf("Expected message %q, but was %q", expected, errors[0].Message)
+	}
+	if rev := lock.Revision(tag); rev != "b1" {
+		t.Errorf("Expected the moved tag to keep its recorded commit, but was %q", rev)
+	}
+
+	tag.CheckoutSpec = "v1.1.0"
+	if errors := lock.Update([]*Dep{tag}, nil, fakeRevisions(map[string]string{"github.com/b/lib": "b2"})); len(errors) != 0 {
+		t.Errorf("Expected a new tag to be recorded, but was %v", errors)
+	}
+}
+
+func TestResolverRequireImmutable(t *testing.T) {
+	config := setupTestConfig(`
+[deps.a]
+  import = "github.com/a/lib"
+  branch = "master"
+[deps.b]
+  import = "github.com/b/lib"
+  tag = "v1.0.0"
+[deps.c]
+  import = "github.com/c/lib"
+
+[policy]
+require-immutable = true
+`)
+
+	createVendorConfig("github.com/b/lib", `
+[deps.d]
+  import = "github.com/d/lib"
+  branch = "develop"
+`)
+
+	ioutil.WriteFile(config.LockPath(), []byte(`
+[deps.lib]
+import = "github.com/a/lib"
+branch = "master"
+revision = "a1"
+`), 0644)
+	lock, err := LoadLock(config.LockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deps := config.LoadDependencyModel(NewGraph())
+	fetched := []string{}
+	resolver := NewResolver(config.Repository)
+	resolver.Policies = []*Policy{config.Policy}
+	resolver.Lock = lock
+	resolver.Fetch = func(dep *Dep) {
+		fetched = append(fetched, dep.Import+"@"+dep.locked)
+	}
+	resolver.Load(deps)
+
+	fetchedList := strings.Join(sortedStrings(fetched), " ")
+	if fetchedList != "github.com/a/lib@a1 github.com/b/lib@" {
+		t.Errorf("Expected the locked branch and the tag to be fetched, but fetched %s", fetchedList)
+	}
+
+	errors := []string{}
+	for _, e := range resolver.Errors {
+		if e.Kind != MutableDep {
+			t.Errorf("Expected mutable-dep errors, but was %s", e.Kind)
+		}
+		errors = append(errors, e.String())
+	}
+	expected := []string{
+		"github.com/c/lib in gopack.config points at the default branch, which can move: pin a commit or a tag, or record its commit in gopack.lock\n",
+		"github.com/d/lib in the gopack.config of github.com/b/lib points at branch develop, which can move: pin a commit or a tag, or record its commit in gopack.lock\n",
+	}
+	if strings.Join(sortedStrings(errors), "") != strings.Join(expected, "") {
+		t.Errorf("Expected errors:\n%s\nbut were:\n%s", strings.Join(expected, ""), strings.Join(errors, ""))
+	}
+}
+
+func TestLockKeepsSkippedDeps(t *testing.T) {
+	config := setupTestConfig(`
+[deps.a]
+  import = "github.com/a/lib"
+  branch = "master"
+[deps.t]
+  import = "github.com/t/lib"
+  branch = "master"
+  group = "test"
+`)
+	ioutil.WriteFile(config.LockPath(), []byte(`
+[deps.lib]
+import = "github.com/t/lib"
+branch = "master"
+revision = "t1"
+
+[deps.lib-2]
+import = "github.com/gone/lib"
+revision = "g1"
+`), 0644)
+	lock, err := LoadLock(config.LockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gp build leaves the test group out
+	resolver := NewResolver(config.Repository)
+	resolver.Fetch = func(dep *Dep) {}
+	resolver.Load(config.LoadDependencyModel(NewGraph()))
+	if len(resolver.Skipped) != 1 || resolver.Skipped[0].Import != "github.com/t/lib" {
+		t.Fatalf("Expected the test dependency to be skipped, but was %v", resolver.Skipped)
+	}
+
+	lock.Update(resolver.Deps, resolver.Skipped, fakeRevisions(map[string]string{"github.com/a/lib": "a1"}))
+	locked := []string{}
+	for importPath, dep := range lock.Deps {
+		locked = append(locked, importPath+"@"+dep.Revision)
+	}
+	if lockedList := strings.Join(sortedStrings(locked), " "); lockedList != "github.com/a/lib@a1 github.com/t/lib@t1" {
+		t.Errorf("Expected the skipped test dependency to keep its entry and the removed one to be dropped, but was %s", lockedList)
+	}
+}
+
+func TestRequireImmutableLoadsUnchangedConfig(t *testing.T) {
+	config := setupTestConfig(`
+[deps.b]
+  import = "github.com/b/lib"
+  tag = "v1.0.0"
+`)
+	config.WriteChecksum()
+	if deps := loadConfiguration(config); deps != nil {
+		t.Fatalf("Expected nothing to load for an unchanged config of tags, but was %v", deps)
+	}
+
+	config.Policy.RequireImmutable = true
+	if deps := loadConfiguration(config); deps == nil || len(deps.DepList) != 1 {
+		t.Errorf("Expected require-immutable to check the dependencies of an unchanged config, but was %v", deps)
+	}
+}
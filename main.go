@@ -5,13 +5,16 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 const (
 	GopackVersion      = "0.20.dev"
 	GopackDir          = ".gopack"
 	GopackChecksum     = ".gopack/checksum"
+	GopackTestChecksum = ".gopack/checksum-test"
 	GopackTestProjects = ".gopack/test-projects"
+	GopackLock         = "gopack.lock"
 	VendorDir          = ".gopack/vendor"
 )
 
@@ -19,6 +22,7 @@ const (
 	Blue     = uint8(94)
 	Green    = uint8(92)
 	Red      = uint8(31)
+	Yellow   = uint8(93)
 	Gray     = uint8(90)
 	EndColor = "\033[0m"
 )
@@ -26,6 +30,15 @@ const (
 var (
 	pwd        string
 	showColors = true
+	// treat every kind of validation error as an error
+	strictValidation bool
+	skipValidation   bool
+	// reject dependencies that can move, like with require-immutable in the policy
+	requireImmutable bool
+	// run the command across the members of the enclosing workspace
+	workspaceMode bool
+	// fetch the dependencies restricted to other platforms too, for trees built anywhere
+	allPlatforms bool
 )
 
 func main() {
@@ -36,45 +49,221 @@ func main() {
 	// localize GOPATH
 	setupEnv()
 
-	p, err := AnalyzeSourceTree(".")
+	// gopack flags aren't passed on to go
+	os.Args = append(os.Args[:1], parseFlags(os.Args[1:])...)
+
+	if workspaceMode {
+		root, err := FindWorkspace(pwd)
+		if err != nil {
+			fail(err)
+		}
+		// members share the vendor tree at the root of the workspace
+		pwd = root
+		setGopath()
+		runWorkspace(NewWorkspace(root), os.Args[1:])
+		return
+	}
+
+	first := os.Args[1]
+	if first == "config" {
+		configCommand(os.Args[2:])
+		return
+	}
+	if first == "import" {
+		importManifest(os.Args[2:])
+		return
+	}
+
+	// vendored trees and exported modules are tested and cross-compiled without gopack
+	allPlatforms = first == "vendor" || first == "export"
+	config := NewConfig(".")
+	config.IncludeTests = first == "test" || allPlatforms
+	if strictValidation {
+		config.Severities = Severities{}
+	}
+	if requireImmutable {
+		config.Policy.RequireImmutable = true
+	}
+
+	p, err := AnalyzeSourceTreeContext(".", NewBuildContext(os.Args[1:]), config)
 	if err != nil {
 		fail(err)
 	}
 
-	deps := loadDependencies(".", p)
+	if first == "fix" {
+		fixConfiguration(config, p, os.Args[2:])
+		return
+	}
+
+	if first == "vendor" {
+		vendorDependencies(config, p, os.Args[2:])
+		return
+	}
+
+	if first == "export" {
+		exportCommand(config, p, os.Args[2:])
+		return
+	}
+
+	if first == "licenses" {
+		printLicenses(config, p, os.Args[2:])
+		return
+	}
+
+	if first == "audit" {
+		auditDependencies(config, p, os.Args[2:])
+		return
+	}
+
+	deps := loadDependencies(config, p)
 
-	first := os.Args[1]
 	if first == "dependencytree" {
 		deps.PrintDependencyTree()
 	} else if first == "stats" {
-		p.PrintSummary()
+		statsCommand(config, p, os.Args[2:])
 	} else {
 		// run the specified command
 		runCommand(deps)
 	}
 }
 
-func loadDependencies(root string, p *ProjectStats) *Dependencies {
-	config, dependencies := loadConfiguration(root)
+func loadDependencies(config *Config, p *ProjectStats) *Dependencies {
+	dependencies := loadConfiguration(config)
 	if dependencies != nil {
 		announceGopack()
-		failWith(dependencies.Validate(p))
+		validateWith(config, dependencies.Validate(p))
 		// prepare dependencies
-		loadTransitiveDependencies(dependencies)
+		lock := loadLock(config.LockPath())
+		resolver := loadTransitiveDependencies(dependencies, lock, []*Policy{config.Policy}, config.Repository)
+		validateWith(config, resolver.Errors)
+		// packages, licenses and revisions can only be found once the dependencies are fetched
+		validateWith(config, lock.Update(resolver.Deps, resolver.Skipped, scmRevision))
+		validateWith(config, dependencies.ValidatePackages(p))
+		validateWith(config, validateReachability(config, dependencies, p))
+		validateWith(config, validateLicenses(config, resolver.Deps))
 		config.WriteChecksum()
+		writeLock(lock)
 	}
 
 	return dependencies
 }
 
-func loadConfiguration(dir string) (*Config, *Dependencies) {
+// Validate and fetch every dependency, including the ones required by
+// the configs of the dependencies, even when the checksum didn't change.
+// Returns nil when the project has no dependencies.
+func resolveDependencies(config *Config, p *ProjectStats) []*Dep {
+	importGraph := NewGraph()
+	config.InitRepo(importGraph)
+	deps, _ := config.DependencyModel(importGraph)
+	if deps == nil {
+		return nil
+	}
+
+	announceGopack()
+	validateWith(config, deps.Validate(p))
+
+	lock := loadLock(config.LockPath())
+	resolver := NewResolver(config.Repository)
+	resolver.Policies = []*Policy{config.Policy}
+	resolver.Lock = lock
+	resolver.Fetch = func(dep *Dep) {
+		// the vendor tree may have been removed since the last fetch
+		if _, err := os.Stat(dep.Src()); os.IsNotExist(err) {
+			dep.fetch = true
+		}
+		fetchDependency(dep)
+	}
+	resolver.Load(deps)
+	validateWith(config, resolver.Errors)
+	validateWith(config, lock.Update(resolver.Deps, resolver.Skipped, scmRevision))
+	validateWith(config, deps.ValidatePackages(p))
+	validateWith(config, validateReachability(config, deps, p))
+	validateWith(config, validateLicenses(config, resolver.Deps))
+	config.WriteChecksum()
+	writeLock(lock)
+
+	return resolver.Deps
+}
+
+// The dependencies checked out in the vendor tree, including the ones required
+// by their configs, without fetching anything: commands only reading the
+// checkouts fail when one is missing instead.
+func vendoredDependencies(config *Config) []*Dep {
 	importGraph := NewGraph()
-	config := NewConfig(dir)
 	config.InitRepo(importGraph)
+	deps, _ := config.DependencyModel(importGraph)
+	if deps == nil {
+		return nil
+	}
 
-	dependencies := config.LoadDependencyModel(importGraph)
+	resolver := NewResolver(config.Repository)
+	resolver.Fetch = func(dep *Dep) {
+		if _, err := os.Stat(dep.Src()); os.IsNotExist(err) {
+			failf("%s isn't in %s, run gp first\n", dep.Import, VendorDir)
+		}
+	}
+	resolver.Load(deps)
+	return resolver.Deps
+}
 
-	return config, dependencies
+func validateWith(config *Config, all []*ProjectError) {
+	validateWithSeverities(config.Severities, all)
+}
+
+func validateWithSeverities(severities Severities, all []*ProjectError) {
+	if !skipValidation {
+		errors, warnings := severities.Split(all)
+		warnWith(warnings)
+		failWith(errors)
+	}
+}
+
+// Take the gopack flags given before the command, the ones after it are the go command's.
+func parseFlags(args []string) []string {
+	rest := []string{}
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return append(rest, args[i:]...)
+		}
+		switch arg {
+		case "--strict":
+			strictValidation = true
+		case "--no-validate":
+			skipValidation = true
+		case "--require-immutable":
+			requireImmutable = true
+		case "-w", "--workspace":
+			workspaceMode = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest
+}
+
+// The dependency model, nil when nothing needs to be fetched unless the policy
+// requires immutable dependencies: the configs of the dependencies and the lock
+// are checked on every run then, the checkouts only being pinned again.
+func loadConfiguration(config *Config) *Dependencies {
+	importGraph := NewGraph()
+	config.InitRepo(importGraph)
+
+	deps, fetchDeps := config.DependencyModel(importGraph)
+	if !fetchDeps && !config.Policy.RequireImmutable {
+		return nil
+	}
+	return deps
+}
+
+// gp stats [--diff <revision>|--reachability|--packages] [--symbols]
+func statsCommand(config *Config, p *ProjectStats, args []string) {
+	if flagValue(args, "--diff", "") != "" {
+		printStatsDiff(config, p, args)
+	} else if hasFlag(args, "--reachability") {
+		printReachability(config, p, args)
+	} else {
+		printStats(config, p, args)
+	}
 }
 
 func runCommand(deps *Dependencies) {
@@ -95,24 +284,70 @@ func runCommand(deps *Dependencies) {
 	}
 }
 
-func loadTransitiveDependencies(dependencies *Dependencies) {
-	dependencies.VisitDeps(
-		func(dep *Dep) {
-			fmtcolor(Gray, "updating %s\n", dep.Import)
-			err := dep.goGetUpdate()
-			if err != nil {
-				fail(err)
-			}
+// Fetch the dependencies and the ones in their configs, never fetching
+// the project's own repository or what the policies disallow.
+func loadTransitiveDependencies(dependencies *Dependencies, lock *Lock, policies []*Policy, repos ...string) *Resolver {
+	resolver := NewResolver(repos...)
+	resolver.Policies = policies
+	resolver.Lock = lock
+	resolver.Load(dependencies)
+	return resolver
+}
+
+func loadLock(path string) *Lock {
+	lock, err := LoadLock(path)
+	if err != nil {
+		failf("%s\n", err)
+	}
+	return lock
+}
+
+func writeLock(lock *Lock) {
+	if err := lock.WriteFile(); err != nil {
+		fail(err)
+	}
+}
+
+// The vendored packages are only complete once the dependencies are fetched.
+func validateReachability(config *Config, deps *Dependencies, p *ProjectStats) []*ProjectError {
+	r := AnalyzeReachability(NewBuildContext(os.Args[1:]), p, config.Dir, config.Repository)
+	return deps.ValidateReachability(p, r)
+}
+
+func validateLicenses(config *Config, deps []*Dep) []*ProjectError {
+	if len(config.Policy.DenyLicenses) == 0 {
+		return []*ProjectError{}
+	}
+	licenses, err := DetectAllLicenses(deps)
+	if err != nil {
+		fail(err)
+	}
+	return config.Policy.ValidateLicenses(licenses)
+}
+
+func fetchDependency(dep *Dep) {
+	fmtcolor(Gray, "updating %s\n", dep.Import)
+	err := dep.goGetUpdate()
+	if err != nil {
+		fail(err)
+	}
+
+	if dep.locked != "" {
+		fmtcolor(Gray, "pointing %s at commit %s from %s\n", dep.Import, dep.locked, GopackLock)
+		pinned := *dep
+		pinned.CheckoutFlag, pinned.CheckoutSpec = CommitFlag, dep.locked
+		pinned.switchToBranchOrTag()
+	} else if dep.CheckoutType() != "" {
+		fmtcolor(Gray, "pointing %s at %s %s\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec)
+		dep.switchToBranchOrTag()
+	}
 
-			if dep.CheckoutType() != "" {
-				fmtcolor(Gray, "pointing %s at %s %s\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec)
-				dep.switchToBranchOrTag()
-			}
-			transitive := dep.LoadTransitiveDeps(dependencies.ImportGraph)
-			if transitive != nil {
-				loadTransitiveDependencies(transitive)
-			}
-		})
+	if dep.VerifySignature {
+		fmtcolor(Gray, "verifying the signature of %s\n", dep.Import)
+		if err := dep.verifySignature(); err != nil {
+			failf("%s - %s\n", dep.Import, err)
+		}
+	}
 }
 
 // Set the working directory.
@@ -133,14 +368,24 @@ func setPwd() {
 	pwd = dir
 }
 
-// set GOPATH to the local vendor dir
+// localize GOPATH
 func setupEnv() {
 	setPwd()
+	setGopath()
+}
+
+// set GOPATH to the vendor dir under pwd
+func setGopath() {
 	vendor := fmt.Sprintf("%s/%s", pwd, VendorDir)
 	err := os.Setenv("GOPATH", vendor)
 	if err != nil {
 		fail(err)
 	}
+	// keep building from the vendor dir next to a go.mod exported by gp export gomod
+	err = os.Setenv("GO111MODULE", "off")
+	if err != nil {
+		fail(err)
+	}
 }
 
 func fmtcolor(c uint8, s string, args ...interface{}) {
@@ -193,6 +438,12 @@ func failWith(errors []*ProjectError) {
 	}
 }
 
+func warnWith(warnings []*ProjectError) {
+	for _, w := range warnings {
+		fmtcolor(Yellow, "warning: %s\n", strings.TrimRight(w.String(), "\n"))
+	}
+}
+
 func announceGopack() {
 	fmtcolor(104, "/// g o p a c k ///")
 	fmt.Println()
@@ -23,3 +23,14 @@ func TestSetPwdAppConfig(t *testing.T) {
 		t.Errorf("Expected pwd to be %s but it was %s.\n", dir, pwd)
 	}
 }
+
+func TestParseFlags(t *testing.T) {
+	args := parseFlags([]string{"--strict", "test", "--no-validate", "-w", "./..."})
+	if len(args) != 4 || args[0] != "test" || args[1] != "--no-validate" || args[2] != "-w" {
+		t.Errorf("Expected gopack flags to be removed before the command only, but args were %v\n", args)
+	}
+	if !strictValidation || skipValidation || workspaceMode {
+		t.Errorf("Expected only the gopack flags before the command to be set\n")
+	}
+	strictValidation = false
+}
@@ -3,21 +3,31 @@ package main
 import (
 	"fmt"
 	"github.com/pelletier/go-toml"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 )
 
 const (
-	ImportProp = "import"
-	BranchProp = "branch"
-	CommitProp = "commit"
-	TagProp    = "tag"
-	BranchFlag = 1 << 0
-	CommitFlag = 1 << 1
-	TagFlag    = 1 << 2
+	ImportProp          = "import"
+	BranchProp          = "branch"
+	CommitProp          = "commit"
+	TagProp             = "tag"
+	GroupProp           = "group"
+	SourceProp          = "source"
+	OSProp              = "os"
+	ArchProp            = "arch"
+	KeyringProp         = "keyring"
+	VerifySignatureProp = "verify-signature"
+	TestGroup           = "test"
+	BranchFlag          = 1 << 0
+	CommitFlag          = 1 << 1
+	TagFlag             = 1 << 2
 )
 
 type Dependencies struct {
@@ -33,16 +43,38 @@ type Dep struct {
 	CheckoutFlag uint8
 	// the name of the thing to checkout whether it be a commit, branch, or tag
 	CheckoutSpec string
+	// the group the dependency belongs to, TestGroup or none
+	Group string
+	// git repository to clone instead of the import path, a mirror for instance
+	Source string
+	// platforms the dependency is restricted to, all of them when empty
+	OS   []string
+	Arch []string
+	// whether the signature of the tag or commit is verified against the keyring after checkout
+	VerifySignature bool
+	Keyring         string
 
 	fetch bool
+	// not needed for the current command
+	skip bool
+	// restricted to other platforms
+	offPlatform bool
+	// commit recorded in gopack.lock, checked out instead of the branch
+	locked string
+	// repository cloned without the imports go get would fetch from any host,
+	// and the import path of its root
+	cloneURL  string
+	cloneRoot string
 }
 
 func NewDependency(repo string) *Dep {
 	return &Dep{Import: repo}
 }
 
+// Find the dependency that provides importPath, either because it is
+// a prefix of importPath or because it is fetched from the same repository.
 func (d *Dependencies) IncludesDependency(importPath string) (*Node, bool) {
-	node := d.ImportGraph.Search(importPath)
+	node := d.ImportGraph.SearchRepository(importPath)
 	return node, node != nil
 }
 
@@ -54,11 +86,76 @@ func (d *Dep) Fetch(all bool) bool {
 func (d *Dep) setCheckout(t *toml.TomlTree, key string, flag uint8) {
 	s := t.Get(key)
 	if s != nil {
-		d.CheckoutSpec = s.(string)
-		d.CheckoutFlag |= flag
+		d.checkout(flag, s.(string))
 	}
 }
 
+func (d *Dep) checkout(flag uint8, spec string) {
+	d.CheckoutSpec = spec
+	d.CheckoutFlag |= flag
+}
+
+func (d *Dep) setGroup(t *toml.TomlTree, group string) {
+	d.Group = group
+	if s := t.Get(GroupProp); s != nil {
+		d.Group = s.(string)
+	}
+	if d.Group != "" && d.Group != TestGroup {
+		failf("%s - unknown group %s\n", d.Import, d.Group)
+	}
+}
+
+func (d *Dep) setSource(t *toml.TomlTree) {
+	if s := t.Get(SourceProp); s != nil {
+		d.Source = s.(string)
+	}
+}
+
+func (d *Dep) setPlatforms(t *toml.TomlTree) {
+	d.OS, _ = getStrings(t, OSProp)
+	d.Arch, _ = getStrings(t, ArchProp)
+}
+
+// keyring is the default keyring of the config, relative paths are relative to dir.
+func (d *Dep) setSignature(t *toml.TomlTree, keyring, dir string) {
+	d.VerifySignature, _ = getBool(t, VerifySignatureProp)
+	if s, _ := getString(t, KeyringProp); s != "" {
+		keyring = s
+	}
+	if keyring != "" && !filepath.IsAbs(keyring) {
+		keyring = filepath.Join(dir, keyring)
+	}
+	d.Keyring = keyring
+	if d.VerifySignature && d.Keyring == "" {
+		failf("%s - verify-signature needs a keyring, in the dependency or at the top of gopack.config\n", d.Import)
+	}
+}
+
+func (d *Dep) verifySignature() error {
+	scm, err := d.Scm()
+	if err != nil {
+		return err
+	}
+	return scm.Verify(d, d.Keyring)
+}
+
+// Whether the dependency is needed when building for goos and goarch.
+func (d *Dep) OnPlatform(goos, goarch string) bool {
+	return matchesPlatform(d.OS, goos) && matchesPlatform(d.Arch, goarch)
+}
+
+func matchesPlatform(allowed []string, platform string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, p := range allowed {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *Dep) CheckValidity() {
 	f := d.CheckoutFlag
 	if f&(f-1) != 0 {
@@ -77,6 +174,10 @@ func (d *Dependencies) String() string {
 }
 
 func (d *Dependencies) PrintDependencyTree() {
+	d.WriteDependencyTree(os.Stdout)
+}
+
+func (d *Dependencies) WriteDependencyTree(w io.Writer) {
 	d.ImportGraph.PreOrderVisit(
 		func(n *Node, depth int) {
 			indent := strings.Repeat(" ", depth*2)
@@ -86,9 +187,9 @@ func (d *Dependencies) PrintDependencyTree() {
 				bullet = "-"
 			}
 			if dep == nil {
-				fmt.Printf("%s%s %s\n", indent, bullet, n.Key)
+				fmt.Fprintf(w, "%s%s %s\n", indent, bullet, n.Key)
 			} else {
-				fmt.Printf("%s%s %s @ %s\n", indent, bullet, dep.Import, dep.CheckoutSpec)
+				fmt.Fprintf(w, "%s%s %s @ %s\n", indent, bullet, dep.Import, dep.CheckoutSpec)
 			}
 		})
 }
@@ -187,47 +288,150 @@ func cdHome() error {
 // update the git repo for this dep
 func (d *Dep) goGetUpdate() (err error) {
 	if d.fetch {
+		if d.Source != "" {
+			return d.fetchSource()
+		}
+		if d.cloneURL != "" {
+			return d.cloneRepository()
+		}
 		cmd := exec.Command("go", "get", "-d", "-u", d.Import)
 		err = cmd.Run()
 	}
 	return
 }
 
+// clone the source repository into the vendor tree, or fetch it when it's already there
+func (d *Dep) fetchSource() error {
+	return fetchRepository(d.Source, d.Src())
+}
+
+// clone the repository the import was resolved to, like fetchSource
+func (d *Dep) cloneRepository() error {
+	return fetchRepository(d.cloneURL, NewDependency(d.cloneRoot).Src())
+}
+
+func fetchRepository(url, dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		os.MkdirAll(filepath.Dir(dir), 0755)
+		return exec.Command("git", "clone", url, dir).Run()
+	}
+
+	for _, args := range [][]string{{"remote", "set-url", "origin", url}, {"fetch", "--tags", "origin"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *Dep) LoadTransitiveDeps(importGraph *Graph) *Dependencies {
 	configPath := path.Join(d.Src(), "gopack.config")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil
 	}
-	config := NewConfig(d.Src())
+	config := NewDependencyConfig(d.Src())
 	return config.LoadDependencyModel(importGraph)
 }
 
+// Validate the dependencies against the imports found in the source tree.
+// Errors are grouped by kind and sorted by import path
+// so the report is the same on every run.
 func (d *Dependencies) Validate(p *ProjectStats) []*ProjectError {
-	errors := []*ProjectError{}
+	unmanaged := []*ProjectError{}
+	testDeps := []*ProjectError{}
+	unused := []*ProjectError{}
 	includedDeps := make(map[string]*Dep)
+	usedRepos := make(map[string]bool)
 
-	for path, s := range p.ImportStatsByPath {
+	for _, path := range p.ImportPaths() {
+		s := p.ImportStatsByPath[path]
 		node, found := d.IncludesDependency(path)
 		if s.Remote {
 			if found {
 				includedDeps[node.Dependency.Import] = node.Dependency
+				usedRepos[RepoRoot(path)] = true
+				if node.Dependency.Group == TestGroup && s.InProduction() {
+					testDeps = append(testDeps, TestDependencyImportError(s))
+				}
 			} else {
 				// report a validation error with the locations in source
 				// where an import is used but unmanaged in gopack.config
-				errors = append(errors, UnmanagedImportError(s))
+				unmanaged = append(unmanaged, UnmanagedImportError(s))
 			}
 		}
 	}
 
-	for _, dep := range d.DepList {
+	depList := make([]*Dep, len(d.DepList))
+	copy(depList, d.DepList)
+	sort.Sort(depsByImport(depList))
+
+	for _, dep := range depList {
 		_, found := includedDeps[dep.Import]
-		if !found && !p.IsImportUsed(dep.Import) {
-			errors = append(errors, UnusedDependencyError(dep.Import))
+		// imports of other platforms aren't analyzed
+		if !found && !dep.offPlatform && !usedRepos[RepoRoot(dep.Import)] && !p.IsImportUsed(dep.Import) {
+			unused = append(unused, UnusedDependencyError(dep.Import))
+		}
+	}
+
+	errors := append(unmanaged, testDeps...)
+	return append(errors, unused...)
+}
+
+// Validate that every import provided by a fetched dependency
+// is a Go package in the vendor tree.
+func (d *Dependencies) ValidatePackages(p *ProjectStats) []*ProjectError {
+	errors := []*ProjectError{}
+
+	for _, path := range p.ImportPaths() {
+		s := p.ImportStatsByPath[path]
+		if !s.Remote {
+			continue
+		}
+		node, found := d.IncludesDependency(path)
+		if !found || node.Dependency.skip {
+			continue
+		}
+		if !NewDependency(path).isPackage() {
+			errors = append(errors, MissingPackageError(s, node.Dependency))
 		}
 	}
+
 	return errors
 }
 
+// Whether the vendor tree has Go files for this import.
+func (d *Dep) isPackage() bool {
+	files, err := filepath.Glob(filepath.Join(d.Src(), "*.go"))
+	return err == nil && len(files) > 0
+}
+
+// Describe the checked out revision, for messages.
+func (d *Dep) Revision() string {
+	rev := ""
+	if scm, err := d.Scm(); err == nil {
+		rev, _ = scm.Revision(d)
+	}
+
+	if d.CheckoutType() == "" {
+		if rev == "" {
+			return "the default branch"
+		}
+		return fmt.Sprintf("revision %s", rev)
+	}
+	if rev == "" || rev == d.CheckoutSpec {
+		return fmt.Sprintf("%s %s", d.CheckoutType(), d.CheckoutSpec)
+	}
+	return fmt.Sprintf("%s %s (%s)", d.CheckoutType(), d.CheckoutSpec, rev)
+}
+
+type depsByImport []*Dep
+
+func (d depsByImport) Len() int           { return len(d) }
+func (d depsByImport) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d depsByImport) Less(i, j int) bool { return d[i].Import < d[j].Import }
+
 func ShowValidationErrors(errors []*ProjectError) {
 	for _, e := range errors {
 		fmt.Errorf("%s\n", e.String())
@@ -94,7 +94,7 @@ func TestTransitiveDependencies(t *testing.T) {
 
 	config := NewConfig(pwd)
 	dependencies := config.LoadDependencyModel(NewGraph())
-	loadTransitiveDependencies(dependencies)
+	loadTransitiveDependencies(dependencies, nil, []*Policy{}, "")
 
 	dep := path.Join(pwd, VendorDir, "src", "github.com", "calavera", "testGoPack")
 	if _, err := os.Stat(dep); os.IsNotExist(err) {
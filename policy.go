@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"strings"
+)
+
+const (
+	PolicyProp           = "policy"
+	DenyLicensesProp     = "deny-licenses"
+	AllowHostsProp       = "allow-hosts"
+	DenyImportsProp      = "deny-imports"
+	RequireImmutableProp = "require-immutable"
+)
+
+// Rules the dependencies must follow, set in the [policy] table.
+type Policy struct {
+	// licenses the dependencies can't have, "GPL" denies every version of the GPL
+	DenyLicenses []string
+	// hosts dependencies can be fetched from, any host when empty
+	AllowHosts []string
+	// import paths that can't be dependencies, along with their packages
+	DenyImports []string
+	// reject branch and unpinned dependencies unless gopack.lock records their commit
+	RequireImmutable bool
+}
+
+func loadPolicy(t *toml.TomlTree) (*Policy, error) {
+	policy := &Policy{DenyLicenses: []string{}, AllowHosts: []string{}, DenyImports: []string{}}
+	policyTree, err := getTable(t, PolicyProp)
+	if err != nil || policyTree == nil {
+		return policy, err
+	}
+
+	if policy.DenyLicenses, err = getStrings(policyTree, DenyLicensesProp); err != nil {
+		return nil, err
+	}
+	if policy.AllowHosts, err = getStrings(policyTree, AllowHostsProp); err != nil {
+		return nil, err
+	}
+	if policy.DenyImports, err = getStrings(policyTree, DenyImportsProp); err != nil {
+		return nil, err
+	}
+	policy.RequireImmutable, err = getBool(policyTree, RequireImmutableProp)
+	return policy, err
+}
+
+// Why the dependency can't be fetched, empty when the policy allows it.
+// Dependencies are fetched from the host of their source, or of their import
+// path when they have none.
+func (p *Policy) Disallows(dep *Dep) string {
+	for _, denied := range p.DenyImports {
+		if dep.Import == denied || strings.HasPrefix(dep.Import, denied+"/") {
+			return fmt.Sprintf("%s is denied by the policy", denied)
+		}
+	}
+
+	if len(p.AllowHosts) == 0 {
+		return ""
+	}
+	host := FetchHost(dep)
+	for _, allowed := range p.AllowHosts {
+		if host == allowed {
+			return ""
+		}
+	}
+	from := host
+	if dep.Source != "" {
+		from = dep.Source
+	}
+	return fmt.Sprintf("it's fetched from %s, which isn't one of the allowed hosts", from)
+}
+
+// Whether the policy restricts what can be fetched, go get then can't
+// be used since it fetches the imports of the dependencies as well.
+func (p *Policy) RestrictsFetching() bool {
+	return len(p.AllowHosts) > 0 || len(p.DenyImports) > 0
+}
+
+// The host a dependency is fetched from, without user or port.
+func FetchHost(dep *Dep) string {
+	location := dep.Import
+	if dep.Source != "" {
+		location = SourceModulePath(dep.Source)
+	}
+	host := strings.SplitN(location, "/", 2)[0]
+	return strings.SplitN(host, ":", 2)[0]
+}
+
+// Whether the license is denied, either by its id or by its family.
+func (p *Policy) DeniesLicense(license string) bool {
+	for _, denied := range p.DenyLicenses {
+		if license == denied || strings.HasPrefix(license, denied+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// Report the dependencies with a denied license.
+func (p *Policy) ValidateLicenses(licenses []*DependencyLicense) []*ProjectError {
+	errors := []*ProjectError{}
+	for _, l := range licenses {
+		for _, license := range l.Licenses {
+			if p.DeniesLicense(license) {
+				errors = append(errors, DeniedLicenseError(l, license))
+			}
+		}
+	}
+	return errors
+}
+
+// Whether the license id or family is one the classifier can detect.
+func knownLicense(license string) bool {
+	if license == UnknownLicense {
+		return true
+	}
+	for _, c := range licenseClassifiers {
+		if c.License == license || strings.HasPrefix(c.License, license+"-") {
+			return true
+		}
+	}
+	return false
+}
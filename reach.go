@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// A package of the project and its imports, ignored imports left out.
+type ProjectPackage struct {
+	// absolute directory of the package
+	Dir  string
+	Name string
+	// imports of the package files, and of its _test.go files
+	Imports     []string
+	TestImports []string
+	// whether it has _test.go files, which are built along with the package
+	HasTests bool
+}
+
+// The remote imports reachable from the main packages and the tests of the project,
+// through the packages of the project and the vendored packages.
+type Reachability struct {
+	// what reaches each import: the directory of a project package relative
+	// to the project, or the vendored import importing it
+	From map[string]string
+	// whether a project package imports it
+	Direct map[string]bool
+
+	ctx      *build.Context
+	root     string
+	repo     string
+	packages map[string]*ProjectPackage
+	// project package directories and imports already followed
+	visited map[string]bool
+}
+
+// Follow the imports of the main packages and of the packages with tests, along
+// with the imports of their tests, or of every package when the project is a
+// library without main packages.
+// Examples aren't built with the project so they're never followed.
+func AnalyzeReachability(ctx *build.Context, p *ProjectStats, root, repo string) *Reachability {
+	// the directories of the packages are absolute
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	r := &Reachability{
+		From:     make(map[string]string),
+		Direct:   make(map[string]bool),
+		ctx:      ctx,
+		root:     root,
+		repo:     repo,
+		packages: make(map[string]*ProjectPackage),
+		visited:  make(map[string]bool),
+	}
+
+	built := []*ProjectPackage{}
+	library := true
+	for _, pkg := range p.Packages {
+		r.packages[pkg.Dir] = pkg
+		if !isExample(relativePath(root, pkg.Dir)) {
+			built = append(built, pkg)
+			library = library && pkg.Name != "main"
+		}
+	}
+
+	for _, pkg := range built {
+		if pkg.Name == "main" || library || pkg.HasTests {
+			r.visitPackage(pkg)
+		}
+		for _, importPath := range pkg.TestImports {
+			r.visitImport(pkg, importPath)
+		}
+	}
+	return r
+}
+
+func isExample(rel string) bool {
+	for _, dir := range strings.Split(rel, "/") {
+		if dir == "example" || dir == "examples" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reachability) visitPackage(pkg *ProjectPackage) {
+	if r.visited[pkg.Dir] {
+		return
+	}
+	r.visited[pkg.Dir] = true
+	for _, importPath := range pkg.Imports {
+		r.visitImport(pkg, importPath)
+	}
+}
+
+func (r *Reachability) visitImport(from *ProjectPackage, importPath string) {
+	if pkg := r.projectPackage(from.Dir, importPath); pkg != nil {
+		r.visitPackage(pkg)
+		return
+	}
+	if ImportOrigin(r.ctx, r.repo, importPath) != RemoteOrigin {
+		return
+	}
+	if !r.Direct[importPath] {
+		r.Direct[importPath] = true
+		r.From[importPath] = relativePath(r.root, from.Dir)
+	}
+	r.visitRemote(importPath)
+}
+
+// Follow the imports of the vendored package, the ones of the standard library aside.
+func (r *Reachability) visitRemote(importPath string) {
+	if r.visited[importPath] {
+		return
+	}
+	r.visited[importPath] = true
+
+	pkg, err := r.ctx.ImportDir(NewDependency(importPath).Src(), 0)
+	if err != nil {
+		// not vendored or not a package, reported by the other validations
+		return
+	}
+	for _, imported := range pkg.Imports {
+		if ImportOrigin(r.ctx, "", imported) != RemoteOrigin {
+			continue
+		}
+		if _, found := r.From[imported]; !found {
+			r.From[imported] = importPath
+		}
+		r.visitRemote(imported)
+	}
+}
+
+// The project package imported from dir, nil when it isn't one.
+func (r *Reachability) projectPackage(dir, importPath string) *ProjectPackage {
+	if build.IsLocalImport(importPath) {
+		return r.packages[filepath.Join(dir, importPath)]
+	}
+	if r.repo == "" || (importPath != r.repo && !strings.HasPrefix(importPath, r.repo+"/")) {
+		return nil
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(importPath, r.repo), "/")
+	return r.packages[filepath.Join(r.root, filepath.FromSlash(rel))]
+}
+
+// The reachable import the dependency provides, imported by the project
+// if possible, and what reaches it.
+func (r *Reachability) Reaches(dep *Dep) (importPath, from string, found bool) {
+	imports := []string{}
+	for i := range r.From {
+		if providesImport(dep, i) {
+			imports = append(imports, i)
+		}
+	}
+	sort.Strings(imports)
+
+	for _, i := range imports {
+		if r.Direct[i] {
+			return i, r.From[i], true
+		}
+	}
+	if len(imports) > 0 {
+		return imports[0], r.From[imports[0]], true
+	}
+	return "", "", false
+}
+
+func providesImport(dep *Dep, importPath string) bool {
+	return importPath == dep.Import || strings.HasPrefix(importPath, dep.Import+"/") || RepoRoot(importPath) == RepoRoot(dep.Import)
+}
+
+// Report the dependencies imported by the project only from code that isn't
+// reachable from its main packages or tests, which are effectively unused.
+// Dependencies that aren't imported at all are left to Validate.
+func (d *Dependencies) ValidateReachability(p *ProjectStats, r *Reachability) []*ProjectError {
+	errors := []*ProjectError{}
+
+	depList := make([]*Dep, len(d.DepList))
+	copy(depList, d.DepList)
+	sort.Sort(depsByImport(depList))
+
+	for _, dep := range depList {
+		if dep.skip {
+			continue
+		}
+		positions := []token.Position{}
+		for _, path := range p.ImportPaths() {
+			if s := p.ImportStatsByPath[path]; s.Remote && providesImport(dep, path) {
+				positions = append(positions, s.ReferencePositions...)
+			}
+		}
+		if _, _, found := r.Reaches(dep); len(positions) > 0 && !found {
+			errors = append(errors, UnreachableDependencyError(dep.Import, positions))
+		}
+	}
+	return errors
+}
+
+// gp stats --reachability
+func printReachability(config *Config, p *ProjectStats, args []string) {
+	// the loaded model is nil when the config didn't change, the full one is needed here
+	importGraph := NewGraph()
+	config.InitRepo(importGraph)
+	deps, _ := config.DependencyModel(importGraph)
+	if deps == nil {
+		fmt.Println("No dependencies in gopack.config")
+		return
+	}
+	r := AnalyzeReachability(NewBuildContext(args), p, config.Dir, config.Repository)
+
+	depList := make([]*Dep, len(deps.DepList))
+	copy(depList, deps.DepList)
+	sort.Sort(depsByImport(depList))
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "IMPORT\tREACHABLE\tFROM")
+	for _, dep := range depList {
+		if dep.skip {
+			continue
+		}
+		importPath, from, found := r.Reaches(dep)
+		switch {
+		case !found:
+			fmt.Fprintf(writer, "%s\tno\t\n", dep.Import)
+		case r.Direct[importPath]:
+			fmt.Fprintf(writer, "%s\tdirectly\t%s\n", dep.Import, from)
+		default:
+			fmt.Fprintf(writer, "%s\tindirectly\t%s\n", dep.Import, from)
+		}
+	}
+	writer.Flush()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeReachability(t *testing.T) {
+	config := setupTestConfig(`
+repo = "github.com/acme/app"
+
+[deps.cli]
+import = "github.com/acme/cli"
+
+[deps.log]
+import = "github.com/acme/log"
+
+[deps.assert]
+import = "github.com/acme/assert"
+
+[deps.legacy]
+import = "github.com/acme/legacy"
+
+[deps.demo]
+import = "github.com/acme/demo"
+`)
+	createSourceFixture(NewDependency("github.com/acme/cli").Src(), "cli.go", `package cli
+import "github.com/acme/log/level"
+`)
+	createSourceFixture(path.Join(NewDependency("github.com/acme/log").Src(), "level"), "level.go", "package level\n")
+
+	createSourceFixture(path.Join(pwd, "cmd", "app"), "main.go", `package main
+import "github.com/acme/app/server"
+`)
+	createSourceFixture(path.Join(pwd, "server"), "server.go", `package server
+import "github.com/acme/cli"
+`)
+	createSourceFixture(path.Join(pwd, "server"), "server_test.go", `package server
+import "github.com/acme/assert"
+`)
+	createSourceFixture(path.Join(pwd, "old"), "old.go", `package old
+import "github.com/acme/legacy"
+`)
+	createSourceFixture(path.Join(pwd, "examples", "demo"), "main.go", `package main
+import "github.com/acme/demo"
+`)
+
+	p, err := AnalyzeSourceTreeContext(pwd, &build.Default, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := AnalyzeReachability(&build.Default, p, pwd, config.Repository)
+
+	reached := []string{}
+	for importPath, from := range r.From {
+		reached = append(reached, importPath+" <- "+from)
+	}
+	sort.Strings(reached)
+	expected := []string{
+		"github.com/acme/assert <- server",
+		"github.com/acme/cli <- server",
+		"github.com/acme/log/level <- github.com/acme/cli",
+	}
+	if strings.Join(reached, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected reachable imports:\n%s\nbut were:\n%s", strings.Join(expected, "\n"), strings.Join(reached, "\n"))
+	}
+	if r.Direct["github.com/acme/log/level"] {
+		t.Error("Expected github.com/acme/log/level to be reached through a vendored package")
+	}
+
+	deps, _ := config.DependencyModel(NewGraph())
+	errors := deps.ValidateReachability(p, r)
+	paths := []string{}
+	for _, e := range errors {
+		if e.Kind != UnreachableDep {
+			t.Errorf("Expected an unreachable dependency error, but was %s", e.Kind)
+		}
+		paths = append(paths, e.Path)
+	}
+	if strings.Join(paths, " ") != "github.com/acme/demo github.com/acme/legacy" {
+		t.Errorf("Expected the examples and the unreachable package to be reported, but were %v", paths)
+	}
+	if config.Severities.Of(UnreachableDep) != SeverityWarning {
+		t.Errorf("Expected unreachable dependencies to be warnings by default")
+	}
+}
+
+func TestAnalyzeReachabilityOfLibrary(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(pwd, "lib.go", `package lib
+import "github.com/acme/log"
+`)
+	createSourceFixture(path.Join(pwd, "internal"), "util.go", `package util
+import "github.com/acme/cli"
+`)
+
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := AnalyzeReachability(&build.Default, p, pwd, "")
+	for _, importPath := range []string{"github.com/acme/log", "github.com/acme/cli"} {
+		if !r.Direct[importPath] {
+			t.Errorf("Expected %s to be reachable from a library without main packages", importPath)
+		}
+	}
+}
+
+func TestAnalyzeReachabilityFromWorkingDirectory(t *testing.T) {
+	config := setupTestConfig(`
+repo = "github.com/acme/app"
+
+[deps.cli]
+import = "github.com/acme/cli"
+`)
+	createSourceFixture(path.Join(pwd, "cmd", "app"), "main.go", `package main
+import "github.com/acme/app/server"
+`)
+	createSourceFixture(path.Join(pwd, "server"), "server.go", `package server
+import "github.com/acme/cli"
+`)
+
+	// gp analyzes the tree it runs in from "."
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(pwd); err != nil {
+		t.Fatal(err)
+	}
+	p, err := AnalyzeSourceTreeContext(".", &build.Default, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := AnalyzeReachability(&build.Default, p, config.Dir, config.Repository)
+	if from := r.From["github.com/acme/cli"]; from != "server" {
+		t.Errorf("Expected github.com/acme/cli to be reached from server, but was %q", from)
+	}
+
+	deps, _ := config.DependencyModel(NewGraph())
+	if errors := deps.ValidateReachability(p, r); len(errors) != 0 {
+		t.Errorf("Expected no unreachable dependencies, but was %v", errors)
+	}
+}
+
+func TestAnalyzeReachabilityOfTestedPackage(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(path.Join(pwd, "cmd", "app"), "main.go", "package main\n")
+	createSourceFixture(path.Join(pwd, "lib"), "lib.go", `package lib
+import "github.com/acme/extra"
+`)
+	createSourceFixture(path.Join(pwd, "lib"), "lib_test.go", "package lib\n")
+	createSourceFixture(path.Join(pwd, "untested"), "untested.go", `package untested
+import "github.com/acme/unused"
+`)
+
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := AnalyzeReachability(&build.Default, p, pwd, "")
+	if from := r.From["github.com/acme/extra"]; from != "lib" {
+		t.Errorf("Expected github.com/acme/extra to be reached from the tests of lib, but was %q", from)
+	}
+	if _, found := r.From["github.com/acme/unused"]; found {
+		t.Error("Expected the imports of a package without tests nor importers not to be reachable")
+	}
+}
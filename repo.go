@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Number of path elements in the repository root for well known hosts.
+var repoRootLengths = map[string]int{
+	"github.com":      3,
+	"bitbucket.org":   3,
+	"gitlab.com":      3,
+	"code.google.com": 3,
+	"launchpad.net":   2,
+}
+
+var scmDirs = []string{".git", ".hg", ".svn"}
+
+// The import path of the repository an import is fetched from.
+//
+// Well known hosts are resolved by their path layout, explicit scm suffixes
+// like "example.com/repo.git/pkg" by the suffix, and anything else by looking
+// for the scm directory in the vendor tree. If none of those apply the import
+// is taken to be its own repository.
+func RepoRoot(importPath string) string {
+	parts := strings.Split(importPath, "/")
+
+	if n, ok := repoRootLengths[parts[0]]; ok {
+		if parts[0] == "launchpad.net" && len(parts) > 1 && strings.HasPrefix(parts[1], "~") {
+			n = 4
+		}
+		return joinParts(parts, n)
+	}
+
+	if parts[0] == "gopkg.in" {
+		// gopkg.in/pkg.v1 or gopkg.in/user/pkg.v1
+		if len(parts) > 1 && strings.Contains(parts[1], ".v") {
+			return joinParts(parts, 2)
+		}
+		return joinParts(parts, 3)
+	}
+
+	for i, part := range parts {
+		for _, scm := range scmDirs {
+			if strings.HasSuffix(part, scm) {
+				return joinParts(parts, i+1)
+			}
+		}
+	}
+
+	if root := vendoredRepoRoot(parts); root != "" {
+		return root
+	}
+
+	return importPath
+}
+
+func joinParts(parts []string, n int) string {
+	if n > len(parts) {
+		n = len(parts)
+	}
+	return strings.Join(parts[:n], "/")
+}
+
+// Traverse the vendored source tree backwards until it finds the scm directory.
+func vendoredRepoRoot(parts []string) string {
+	src := path.Join(pwd, VendorDir, "src")
+	for n := len(parts); n > 0; n-- {
+		root := joinParts(parts, n)
+		for _, scm := range scmDirs {
+			if stat, err := os.Stat(path.Join(src, root, scm)); err == nil && stat.IsDir() {
+				return root
+			}
+		}
+	}
+	return ""
+}
+
+var (
+	metaTag  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttr = regexp.MustCompile(`(?is)(name|content)\s*=\s*["']([^"']*)["']`)
+)
+
+// The repository an import path is served from, as announced by the go-import
+// meta tag of its host, which is how go get resolves vanity imports.
+type GoImport struct {
+	// import path of the repository root
+	Prefix  string
+	VCS     string
+	RepoURL string
+}
+
+func lookupGoImport(importPath string) (*GoImport, error) {
+	resp, err := http.Get("https://" + importPath + "?go-get=1")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("https://%s?go-get=1 answered %s", importPath, resp.Status)
+	}
+	return parseGoImport(resp.Body, importPath)
+}
+
+// The go-import meta tag of the page whose prefix provides importPath.
+func parseGoImport(r io.Reader, importPath string) (*GoImport, error) {
+	page, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range metaTag.FindAllString(string(page), -1) {
+		attrs := make(map[string]string)
+		for _, m := range metaAttr.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2]
+		}
+		fields := strings.Fields(attrs["content"])
+		if attrs["name"] != "go-import" || len(fields) != 3 {
+			continue
+		}
+		if importPath == fields[0] || strings.HasPrefix(importPath, fields[0]+"/") {
+			return &GoImport{fields[0], fields[1], fields[2]}, nil
+		}
+	}
+	return nil, fmt.Errorf("no go-import meta tag for %s", importPath)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepoRootKnownHosts(t *testing.T) {
+	roots := map[string]string{
+		"github.com/d2fn/gopack":                 "github.com/d2fn/gopack",
+		"github.com/gorilla/mux/sub":             "github.com/gorilla/mux",
+		"bitbucket.org/user/repo/pkg":            "bitbucket.org/user/repo",
+		"code.google.com/p/go.net/websocket":     "code.google.com/p/go.net",
+		"launchpad.net/goamz/aws":                "launchpad.net/goamz",
+		"launchpad.net/~user/project/series/pkg": "launchpad.net/~user/project/series",
+		"gopkg.in/yaml.v1":                       "gopkg.in/yaml.v1",
+		"gopkg.in/user/pkg.v2/sub":               "gopkg.in/user/pkg.v2",
+		"example.com/repo.git/pkg":               "example.com/repo.git",
+		"github.com/d2fn":                        "github.com/d2fn",
+	}
+
+	for importPath, root := range roots {
+		if actual := RepoRoot(importPath); actual != root {
+			t.Errorf("Expected repository root of %s to be %s, but was %s\n", importPath, root, actual)
+		}
+	}
+}
+
+func TestRepoRootVendored(t *testing.T) {
+	setupTestPwd()
+
+	createScmDep(".git", "git.internal/tools/lib")
+
+	if root := RepoRoot("git.internal/tools/lib/sub/pkg"); root != "git.internal/tools/lib" {
+		t.Errorf("Expected repository root to be found in vendor, but was %s\n", root)
+	}
+
+	if root := RepoRoot("git.internal/other"); root != "git.internal/other" {
+		t.Errorf("Expected unknown repositories to be their own root, but was %s\n", root)
+	}
+}
+
+func TestParseGoImport(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+<meta name="go-import" content="golang.org/x/tools git https://go.googlesource.com/tools">
+<meta content="golang.org/x/text git https://go.googlesource.com/text" name="go-import">
+</head>
+</html>`
+
+	repo, err := parseGoImport(strings.NewReader(page), "golang.org/x/text/unicode/norm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Prefix != "golang.org/x/text" || repo.VCS != "git" || repo.RepoURL != "https://go.googlesource.com/text" {
+		t.Errorf("Expected the repository of golang.org/x/text, but was %v", repo)
+	}
+
+	if _, err := parseGoImport(strings.NewReader(page), "golang.org/x/textual"); err == nil {
+		t.Error("Expected no repository for an import outside the prefixes")
+	}
+}
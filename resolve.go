@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fetches dependencies and, recursively, the dependencies in their gopack.config.
+//
+// Each import is fetched once, by the config closest to the project, so the
+// project's own pins win over the ones of its dependencies. Dependencies on the
+// project's own repository, cycles between configs and dependencies disallowed
+// by a policy are reported as errors instead of being followed.
+type Resolver struct {
+	// the project's own repositories, linked into the vendor tree by InitRepo
+	Repositories []string
+	// every dependency must be allowed by each of the policies before it's fetched
+	Policies []*Policy
+	// revisions branches are pinned to when a policy requires immutable dependencies
+	Lock *Lock
+	// called for each import the first time it's found
+	Fetch func(dep *Dep)
+	// resolves the repository of an import when the policies restrict fetching
+	Lookup func(importPath string) (*GoImport, error)
+	Errors []*ProjectError
+	// the dependencies fetched, in the order they were found
+	Deps []*Dep
+	// dependencies the command doesn't need, like the test group outside
+	// gp test or the ones restricted to other platforms, never fetched
+	Skipped []*Dep
+
+	visited map[string]bool
+	// the imports required by the config of each import loaded so far
+	requires map[string][]string
+}
+
+func NewResolver(repos ...string) *Resolver {
+	return &Resolver{
+		Repositories: repos,
+		Policies:     []*Policy{},
+		Fetch:        fetchDependency,
+		Lookup:       lookupGoImport,
+		Errors:       []*ProjectError{},
+		Deps:         []*Dep{},
+		Skipped:      []*Dep{},
+		visited:      make(map[string]bool),
+		requires:     make(map[string][]string),
+	}
+}
+
+func (r *Resolver) Load(dependencies *Dependencies) {
+	r.load(dependencies, []string{})
+}
+
+// chain holds the imports whose configs led to these dependencies.
+// Every dependency of the config is checked before any of them is fetched.
+func (r *Resolver) load(dependencies *Dependencies, chain []string) {
+	allowed := []*Dep{}
+
+	dependencies.VisitDeps(
+		func(dep *Dep) {
+			if dep.skip {
+				r.Skipped = append(r.Skipped, dep)
+				return
+			}
+			if r.isRepository(dep.Import) {
+				r.Errors = append(r.Errors, SelfDependencyError(dep.Import, chain))
+				return
+			}
+			if len(chain) > 0 {
+				requirer := chain[len(chain)-1]
+				r.requires[requirer] = append(r.requires[requirer], dep.Import)
+				// the dependency may have been loaded through another config than the chain
+				if path := r.requirePath(dep.Import, requirer, map[string]bool{}); path != nil {
+					r.Errors = append(r.Errors, ImportCycleError(append(path, dep.Import)))
+					return
+				}
+			}
+			if r.visited[dep.Import] {
+				return
+			}
+			if reason := r.disallows(dep); reason != "" {
+				r.Errors = append(r.Errors, DisallowedDependencyError(dep.Import, reason, chain))
+				return
+			}
+			if reason := r.resolveClone(dep); reason != "" {
+				r.Errors = append(r.Errors, DisallowedDependencyError(dep.Import, reason, chain))
+				return
+			}
+			if r.requiresImmutable() && dep.CheckoutFlag != CommitFlag && dep.CheckoutFlag != TagFlag {
+				if dep.locked = r.Lock.Revision(dep); dep.locked == "" {
+					r.Errors = append(r.Errors, MutableDependencyError(dep, chain))
+					return
+				}
+			}
+
+			r.visited[dep.Import] = true
+			allowed = append(allowed, dep)
+		})
+
+	for _, dep := range allowed {
+		r.Fetch(dep)
+		r.Deps = append(r.Deps, dep)
+	}
+
+	for _, dep := range allowed {
+		transitive := dep.LoadTransitiveDeps(dependencies.ImportGraph)
+		if transitive != nil {
+			r.load(transitive, append(append([]string{}, chain...), dep.Import))
+		}
+	}
+}
+
+// The imports from one import to another through the configs loaded so far, nil if there's no path.
+func (r *Resolver) requirePath(from, to string, seen map[string]bool) []string {
+	if from == to {
+		return []string{to}
+	}
+	seen[from] = true
+	for _, next := range r.requires[from] {
+		if seen[next] {
+			continue
+		}
+		if path := r.requirePath(next, to, seen); path != nil {
+			return append([]string{from}, path...)
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) isRepository(importPath string) bool {
+	for _, repo := range r.Repositories {
+		if repo != "" && (importPath == repo || strings.HasPrefix(importPath, repo+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) disallows(dep *Dep) string {
+	for _, policy := range r.Policies {
+		if reason := policy.Disallows(dep); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// When the policies restrict fetching, go get can't be used since it fetches the
+// imports of the dependency from any host: its repository is resolved like go get
+// does and cloned on its own instead. Returns why it can't be, if it can't.
+func (r *Resolver) resolveClone(dep *Dep) string {
+	if dep.Source != "" || !r.restrictsFetching() {
+		return ""
+	}
+	repo, err := r.Lookup(dep.Import)
+	if err != nil {
+		return fmt.Sprintf("its repository can't be resolved (%s), set its source", err)
+	}
+	if repo.VCS != "git" {
+		return fmt.Sprintf("its repository %s is a %s one, set the source of a git mirror", repo.RepoURL, repo.VCS)
+	}
+	if reason := r.disallows(&Dep{Import: dep.Import, Source: repo.RepoURL}); reason != "" {
+		return reason
+	}
+	dep.cloneURL, dep.cloneRoot = repo.RepoURL, repo.Prefix
+	return ""
+}
+
+func (r *Resolver) restrictsFetching() bool {
+	for _, policy := range r.Policies {
+		if policy.RestrictsFetching() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) requiresImmutable() bool {
+	for _, policy := range r.Policies {
+		if policy.RequireImmutable {
+			return true
+		}
+	}
+	return false
+}
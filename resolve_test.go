@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func createVendorConfig(importPath, config string) {
+	dir := path.Join(pwd, VendorDir, "src", importPath)
+	createPath(dir)
+	createFixtureConfig(dir, config)
+}
+
+func TestResolverCyclesAndSelfDependencies(t *testing.T) {
+	config := setupTestConfig(`
+repo = "github.com/us/app"
+
+[deps.a]
+  import = "github.com/a/lib"
+  branch = "master"
+[deps.b]
+  import = "github.com/b/lib"
+  branch = "master"
+`)
+
+	// b is loaded from the project's config, not through the config of a
+	createVendorConfig("github.com/a/lib", `
+[deps.b]
+  import = "github.com/b/lib"
+  branch = "master"
+[deps.c]
+  import = "github.com/c/lib"
+  branch = "master"
+`)
+
+	createVendorConfig("github.com/b/lib", `
+[deps.a]
+  import = "github.com/a/lib"
+  branch = "develop"
+[deps.app]
+  import = "github.com/us/app/sub"
+  branch = "master"
+`)
+
+	createVendorConfig("github.com/c/lib", `
+[deps.a]
+  import = "github.com/a/lib"
+  branch = "master"
+`)
+
+	deps := config.LoadDependencyModel(NewGraph())
+
+	fetched := []string{}
+	resolver := NewResolver(config.Repository)
+	resolver.Fetch = func(dep *Dep) {
+		fetched = append(fetched, dep.Import+"@"+dep.CheckoutSpec)
+	}
+	resolver.Load(deps)
+
+	// sorted since the order of the keys in a table isn't deterministic
+	fetchedList := strings.Join(sortedStrings(fetched), " ")
+	if fetchedList != "github.com/a/lib@master github.com/b/lib@master github.com/c/lib@master" {
+		t.Errorf("Expected every dependency to be fetched once with the project's pins, but fetched %s", fetchedList)
+	}
+
+	errors := map[string]string{}
+	cycles := []string{}
+	for _, e := range resolver.Errors {
+		errors[e.Kind] = e.String()
+		if e.Kind == ImportCycle {
+			cycles = append(cycles, e.String())
+		}
+	}
+
+	if len(resolver.Errors) != 3 {
+		t.Fatalf("Expected 3 errors, found %d", len(resolver.Errors))
+	}
+
+	// the cycle between a and b starts with whichever of them is loaded first
+	cycleList := strings.Join(sortedStrings(cycles), "")
+	if cycleList != "gopack.config import cycle: github.com/a/lib -> github.com/b/lib -> github.com/a/lib\n"+
+		"gopack.config import cycle: github.com/a/lib -> github.com/c/lib -> github.com/a/lib\n" &&
+		cycleList != "gopack.config import cycle: github.com/a/lib -> github.com/c/lib -> github.com/a/lib\n"+
+			"gopack.config import cycle: github.com/b/lib -> github.com/a/lib -> github.com/b/lib\n" {
+		t.Errorf("Expected import cycle errors, but were %q", cycles)
+	}
+
+	if errors[SelfDependency] != "github.com/us/app/sub in the gopack.config of github.com/b/lib is this project's own repository\n" {
+		t.Errorf("Expected self dependency error, but was %q", errors[SelfDependency])
+	}
+}
+
+func TestResolverRootSelfDependency(t *testing.T) {
+	config := setupTestConfig(`
+repo = "github.com/us/app"
+
+[deps.app]
+  import = "github.com/us/app"
+  branch = "master"
+`)
+
+	deps := config.LoadDependencyModel(NewGraph())
+
+	resolver := NewResolver(config.Repository)
+	resolver.Fetch = func(dep *Dep) {
+		t.Errorf("Expected %s to not be fetched", dep.Import)
+	}
+	resolver.Load(deps)
+
+	if len(resolver.Errors) != 1 || resolver.Errors[0].Kind != SelfDependency {
+		t.Fatalf("Expected a self dependency error")
+	}
+}
+
+func TestResolverPolicy(t *testing.T) {
+	config := setupTestConfig(`
+[deps.a]
+  import = "github.com/a/lib"
+  branch = "master"
+[deps.internal]
+  import = "git.acme.com/platform/log"
+  branch = "master"
+[deps.evil]
+  import = "evil.com/x/lib"
+  branch = "master"
+[deps.hg]
+  import = "git.acme.com/tools/hg"
+  branch = "default"
+[deps.redirect]
+  import = "git.acme.com/go/redirect"
+  branch = "master"
+
+[policy]
+allow-hosts = ["github.com", "git.acme.com"]
+deny-imports = ["github.com/b/lib"]
+`)
+
+	createVendorConfig("github.com/a/lib", `
+[deps.b]
+  import = "github.com/b/lib/sub"
+  branch = "master"
+[deps.mirror]
+  import = "github.com/c/lib"
+  source = "https://mirror.example.com/c/lib.git"
+  branch = "master"
+`)
+
+	deps := config.LoadDependencyModel(NewGraph())
+
+	fetched := []string{}
+	resolver := NewResolver(config.Repository)
+	resolver.Policies = []*Policy{config.Policy}
+	repos := map[string]*GoImport{
+		"github.com/a/lib":          {"github.com/a/lib", "git", "https://github.com/a/lib"},
+		"git.acme.com/platform/log": {"git.acme.com/platform/log", "git", "https://git.acme.com/platform/log.git"},
+		"git.acme.com/tools/hg":     {"git.acme.com/tools/hg", "hg", "https://git.acme.com/tools/hg"},
+		"git.acme.com/go/redirect":  {"git.acme.com/go/redirect", "git", "https://evil.com/redirect.git"},
+	}
+	resolver.Lookup = func(importPath string) (*GoImport, error) {
+		if repo, found := repos[importPath]; found {
+			return repo, nil
+		}
+		return nil, fmt.Errorf("no go-import meta tag for %s", importPath)
+	}
+	resolver.Fetch = func(dep *Dep) {
+		if len(fetched) == 0 && len(resolver.Errors) == 0 {
+			t.Errorf("Expected every dependency of the config to be checked before %s is fetched", dep.Import)
+		}
+		if dep.cloneURL != repos[dep.Import].RepoURL {
+			t.Errorf("Expected %s to be cloned from its resolved repository without its imports, but was %q", dep.Import, dep.cloneURL)
+		}
+		fetched = append(fetched, dep.Import)
+	}
+	resolver.Load(deps)
+
+	fetchedList := strings.Join(sortedStrings(fetched), " ")
+	if fetchedList != "git.acme.com/platform/log github.com/a/lib" {
+		t.Errorf("Expected only the allowed dependencies to be fetched, but fetched %s", fetchedList)
+	}
+
+	errors := []string{}
+	for _, e := range resolver.Errors {
+		if e.Kind != DisallowedDep {
+			t.Errorf("Expected disallowed-dep errors, but was %s", e.Kind)
+		}
+		errors = append(errors, e.String())
+	}
+	expected := []string{
+		"evil.com/x/lib in gopack.config isn't allowed: it's fetched from evil.com, which isn't one of the allowed hosts\n",
+		"git.acme.com/go/redirect in gopack.config isn't allowed: it's fetched from https://evil.com/redirect.git, which isn't one of the allowed hosts\n",
+		"git.acme.com/tools/hg in gopack.config isn't allowed: its repository https://git.acme.com/tools/hg is a hg one, set the source of a git mirror\n",
+		"github.com/b/lib/sub in the gopack.config of github.com/a/lib isn't allowed: github.com/b/lib is denied by the policy\n",
+		"github.com/c/lib in the gopack.config of github.com/a/lib isn't allowed: it's fetched from https://mirror.example.com/c/lib.git, which isn't one of the allowed hosts\n",
+	}
+	if strings.Join(sortedStrings(errors), "") != strings.Join(expected, "") {
+		t.Errorf("Expected errors:\n%s\nbut were:\n%s", strings.Join(expected, ""), strings.Join(errors, ""))
+	}
+}
+
+func TestFetchHost(t *testing.T) {
+	for source, host := range map[string]string{
+		"":                                     "github.com",
+		"https://user@git.acme.com:8443/c.git": "git.acme.com",
+		"git@git.acme.com:mirror/c.git":        "git.acme.com",
+		"ssh://git@github.com/mirror/c":        "github.com",
+	} {
+		dep := NewDependency("github.com/c/lib")
+		dep.Source = source
+		if actual := FetchHost(dep); actual != host {
+			t.Errorf("Expected the host of %q to be %s, but was %s", source, host, actual)
+		}
+	}
+}
+
+func sortedStrings(s []string) []string {
+	sort.Strings(s)
+	return s
+}
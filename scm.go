@@ -1,11 +1,23 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Scm interface {
 	Checkout(d *Dep) error
+	// the revision currently checked out in the dependency source
+	Revision(d *Dep) (string, error)
+	// the commit time of the revision currently checked out
+	CommitTime(d *Dep) (time.Time, error)
+	// verify the signature of the tag or commit checked out against the keys in keyring
+	Verify(d *Dep, keyring string) error
 }
 
 type Git struct {
@@ -48,3 +60,94 @@ func (s Svn) Checkout(d *Dep) error {
 
 	return cmd.Run()
 }
+
+func (g Git) Revision(d *Dep) (string, error) {
+	return revision(d, "git", "rev-parse", "HEAD")
+}
+
+func (h Hg) Revision(d *Dep) (string, error) {
+	return revision(d, "hg", "log", "-r", ".", "--template", "{node}")
+}
+
+func (s Svn) Revision(d *Dep) (string, error) {
+	return revision(d, "svnversion")
+}
+
+func (g Git) CommitTime(d *Dep) (time.Time, error) {
+	return commitTime(d, "git", "log", "-1", "--format=%ct")
+}
+
+func (h Hg) CommitTime(d *Dep) (time.Time, error) {
+	// hgdate is "<unix time> <offset>"
+	return commitTime(d, "hg", "log", "-r", ".", "--template", "{date|hgdate}")
+}
+
+func (s Svn) CommitTime(d *Dep) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("svn revisions have no commit time")
+}
+
+// Tags are verified with their own signature, anything else with the signature of the commit.
+// The keys are imported into a throwaway GnuPG home so only the keyring is trusted.
+func (g Git) Verify(d *Dep, keyring string) error {
+	home, err := ioutil.TempDir("", "gopack-gnupg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	env := append(os.Environ(), "GNUPGHOME="+home)
+
+	cmd := exec.Command("gpg", "--batch", "--import", keyring)
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("couldn't import the keyring %s: %s", keyring, strings.TrimSpace(string(out)))
+	}
+
+	what, args := "commit "+d.CheckoutSpec, []string{"verify-commit", "HEAD"}
+	switch {
+	case d.CheckoutFlag == TagFlag:
+		what, args = "tag "+d.CheckoutSpec, []string{"verify-tag", d.CheckoutSpec}
+	case d.CheckoutFlag != CommitFlag:
+		what = "the commit checked out"
+	}
+	cmd = exec.Command("git", args...)
+	cmd.Dir = d.Src()
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s has no valid signature from %s: %s", what, keyring, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (h Hg) Verify(d *Dep, keyring string) error {
+	return fmt.Errorf("signatures of hg repositories can't be verified")
+}
+
+func (s Svn) Verify(d *Dep, keyring string) error {
+	return fmt.Errorf("signatures of svn repositories can't be verified")
+}
+
+func commitTime(d *Dep, name string, args ...string) (time.Time, error) {
+	out, err := revision(d, name, args...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("no commit time for %s", d.Import)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+func revision(d *Dep, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = d.Src()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
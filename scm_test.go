@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Run a command with a GnuPG home, failing the test on errors.
+func runWithGnupg(t *testing.T, home, dir string, name string, args ...string) []byte {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+home,
+		"GIT_AUTHOR_NAME=Maintainer", "GIT_AUTHOR_EMAIL=maintainer@example.com",
+		"GIT_COMMITTER_NAME=Maintainer", "GIT_COMMITTER_EMAIL=maintainer@example.com")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s %s failed: %s", name, strings.Join(args, " "), err)
+	}
+	return out
+}
+
+// A throwaway GnuPG home with a key for email, and the keyring exporting its public key.
+func createSigningKey(t *testing.T, email string) (home, keyring string) {
+	home, _ = ioutil.TempDir("", "gopack-signer-")
+	os.Chmod(home, 0700)
+	runWithGnupg(t, home, home, "gpg", "--batch", "--passphrase", "", "--quick-gen-key", email, "ed25519", "sign", "never")
+	keyring = path.Join(home, "keyring.asc")
+	ioutil.WriteFile(keyring, runWithGnupg(t, home, home, "gpg", "--armor", "--export", email), 0644)
+	return home, keyring
+}
+
+func TestGitVerify(t *testing.T) {
+	for _, tool := range []string{"git", "gpg"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s isn't installed", tool)
+		}
+	}
+	setupTestPwd()
+	home, keyring := createSigningKey(t, "maintainer@example.com")
+	defer os.RemoveAll(home)
+	otherHome, otherKeyring := createSigningKey(t, "someone@example.com")
+	defer os.RemoveAll(otherHome)
+
+	dep := NewDependency("github.com/acme/critical")
+	createPath(dep.Src())
+	git := func(args ...string) { runWithGnupg(t, home, dep.Src(), "git", args...) }
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "unsigned")
+	git("tag", "-s", "-u", "maintainer@example.com", "-m", "v1.0.0", "v1.0.0")
+	git("tag", "-a", "-m", "v1.1.0", "v1.1.0")
+	git("commit", "-q", "--allow-empty", "-Smaintainer@example.com", "-m", "signed")
+
+	dep.checkout(TagFlag, "v1.0.0")
+	if err := (Git{}).Verify(dep, keyring); err != nil {
+		t.Errorf("Expected the signed tag to be verified, but was %s", err)
+	}
+	if err := (Git{}).Verify(dep, otherKeyring); err == nil || !strings.HasPrefix(err.Error(), "tag v1.0.0 has no valid signature from "+otherKeyring) {
+		t.Errorf("Expected the tag not to be verified with another keyring, but was %v", err)
+	}
+
+	dep.CheckoutSpec = "v1.1.0"
+	if err := (Git{}).Verify(dep, keyring); err == nil {
+		t.Error("Expected the unsigned tag not to be verified")
+	}
+
+	branch := NewDependency("github.com/acme/critical")
+	branch.checkout(BranchFlag, "master")
+	if err := (Git{}).Verify(branch, keyring); err != nil {
+		t.Errorf("Expected the signed commit checked out to be verified, but was %s", err)
+	}
+
+	if err := (Git{}).Verify(branch, path.Join(home, "missing.asc")); err == nil || !strings.Contains(err.Error(), "couldn't import the keyring") {
+		t.Errorf("Expected a missing keyring to fail, but was %v", err)
+	}
+}
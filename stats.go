@@ -2,46 +2,83 @@ package main
 
 import (
 	"fmt"
-	"go/ast"
-	"go/parser"
+	"go/build"
 	"go/token"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 	"text/tabwriter"
 )
 
+const (
+	// imported from the package's own files
+	ProductionImport = 1 << 0
+	// imported from _test.go files in the package
+	TestImport = 1 << 1
+	// imported from _test.go files in an external _test package
+	XTestImport = 1 << 2
+)
+
+const (
+	RemoteOrigin = 2
+	// packages of the repository configured in gopack.config
+	SelfOrigin   = 1
+	LocalOrigin  = 0
+	StdlibOrigin = -1
+)
+
 type ProjectStats struct {
 	ImportStatsByPath map[string]*ImportStats
+	// packages of the project that were analyzed
+	Packages []*ProjectPackage
 }
 
 type ImportStats struct {
-	Path               string
-	Remote             bool
+	Path string
+	// one of RemoteOrigin, SelfOrigin, LocalOrigin, StdlibOrigin
+	Origin int
+	Remote bool
+	// which of ProductionImport, TestImport, XTestImport reference this import
+	Scope              uint8
 	ReferencePositions []token.Position
+	// where each exported identifier of the import is used, nil until AnalyzeSymbols
+	Symbols map[string][]token.Position
 }
 
 type SummaryItem struct {
 	Origin int
 	Sum    int
 	Path   string
+	// distinct identifiers used, nil when symbols weren't analyzed
+	Symbols []string
 }
 
 func (i SummaryItem) Legend() string {
-	var origin string
-
-	switch i.Origin {
-	case 1:
-		origin = "R"
-	case 0:
-		origin = "L"
-	case -1:
-		origin = "S"
+	legend := fmt.Sprintf("%s\t%s\t%d", originLegend(i.Origin), i.Path, i.Sum)
+	if i.Symbols == nil {
+		return legend
+	}
+	listed := i.Symbols
+	if len(listed) > maxListedSymbols {
+		listed = append(append([]string{}, listed[:maxListedSymbols]...), "...")
 	}
+	return fmt.Sprintf("%s\t%d\t%s", legend, len(i.Symbols), strings.Join(listed, ", "))
+}
 
-	return fmt.Sprintf("%s\t%s\t%d", origin, i.Path, i.Sum)
+func originLegend(origin int) string {
+	switch origin {
+	case RemoteOrigin:
+		return "R"
+	case SelfOrigin:
+		return "P"
+	case LocalOrigin:
+		return "L"
+	case StdlibOrigin:
+		return "S"
+	}
+	return ""
 }
 
 type Summary struct {
@@ -57,37 +94,60 @@ func (s *Summary) Less(i, j int) bool {
 	i1 := s.Items[i]
 	i2 := s.Items[j]
 
-	return i1.Origin > i2.Origin || (i1.Origin == i2.Origin && i1.Sum > i2.Sum)
+	if i1.Origin != i2.Origin {
+		return i1.Origin > i2.Origin
+	}
+	if i1.Sum != i2.Sum {
+		return i1.Sum > i2.Sum
+	}
+	return i1.Path < i2.Path
 }
 
 func NewProjectStats() *ProjectStats {
 	return &ProjectStats{
 		make(map[string]*ImportStats),
+		[]*ProjectPackage{},
 	}
 }
 
+// Analyze the source tree using the default build context,
+// which honors GOOS, GOARCH and CGO_ENABLED from the environment.
 func AnalyzeSourceTree(dir string) (*ProjectStats, error) {
+	return AnalyzeSourceTreeContext(dir, &build.Default, nil)
+}
+
+// Analyze the source tree package by package, skipping files
+// excluded by the build constraints of the given context and paths ignored in the config.
+// testdata, "_" and "." prefixed directories are skipped like the go tool does,
+// as well as nested projects with their own gopack.config and trees exported by gp vendor.
+func AnalyzeSourceTreeContext(dir string, ctx *build.Context, config *Config) (*ProjectStats, error) {
 	ps := NewProjectStats()
+	var ignore *Ignore
+	if config != nil {
+		ignore = config.Ignore
+	}
+	ctx = ignoringContext(dir, ctx, ignore)
 	err := filepath.Walk(
 		dir,
 		func(path string, info os.FileInfo, err error) error {
-			fileDir := filepath.Dir(path)
-			baseName := filepath.Base(path)
-			if strings.HasSuffix(baseName, ".go") {
-				// Bail if not analyzing the gopack dir specifically
-				// and we hit that directory as part of this analysis.
-				// (should only ever be an issue with running gopack on itself and running tests)
-				// (use Contains rather than HasPrefix to handle absolute and relative paths)
-				if !strings.Contains(dir, GopackDir) &&
-					strings.Contains(fileDir, GopackDir) {
-					return nil
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if path != dir {
+				if ignoredByDefault(info.Name()) || ignore.IgnorePath(relativePath(dir, path)) {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "gopack.config")); err == nil {
+					return filepath.SkipDir
 				}
-				e := ps.analyzeSourceFile(path)
-				if e != nil {
-					return e
+				if _, err := os.Stat(filepath.Join(path, VendorManifest)); err == nil {
+					return filepath.SkipDir
 				}
 			}
-			return nil
+			return ps.analyzePackage(ctx, path, config)
 		})
 	if err != nil {
 		return nil, err
@@ -95,36 +155,116 @@ func AnalyzeSourceTree(dir string) (*ProjectStats, error) {
 	return ps, nil
 }
 
-func (ps *ProjectStats) analyzeSourceFile(path string) error {
-	fs := token.NewFileSet()
-	f, err := parser.ParseFile(fs, path, nil, parser.ParseComments)
-	if err != nil {
-		return err
+// Copy the build context so that ignored files are never read.
+func ignoringContext(root string, ctx *build.Context, ignore *Ignore) *build.Context {
+	if ignore == nil || len(ignore.Paths) == 0 {
+		return ctx
 	}
-	for _, i := range f.Imports {
-		err = ps.foundImport(fs, i, path)
+	c := *ctx
+	c.ReadDir = func(dir string) ([]os.FileInfo, error) {
+		infos, err := ioutil.ReadDir(dir)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		kept := []os.FileInfo{}
+		for _, info := range infos {
+			if !ignore.IgnorePath(relativePath(root, filepath.Join(dir, info.Name()))) {
+				kept = append(kept, info)
+			}
 		}
+		return kept, nil
 	}
-	return nil
+	return &c
 }
 
-func (ps *ProjectStats) foundImport(fs *token.FileSet, i *ast.ImportSpec, path string) error {
-	importPath, err := strconv.Unquote(i.Path.Value)
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
 	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (ps *ProjectStats) analyzePackage(ctx *build.Context, dir string, config *Config) error {
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		if _, ok := err.(*build.NoGoError); ok {
+			return nil
+		}
 		return err
 	}
-	ref := fs.Position(i.Pos())
-	_, found := ps.ImportStatsByPath[importPath]
-	if found {
-		ps.ImportStatsByPath[importPath].ReferencePositions = append(ps.ImportStatsByPath[importPath].ReferencePositions, ref)
-	} else {
-		ps.ImportStatsByPath[importPath] = NewImportStats(importPath, ref)
+	ps.foundImports(ctx, config, pkg.ImportPos, ProductionImport)
+	ps.foundImports(ctx, config, pkg.TestImportPos, TestImport)
+	ps.foundImports(ctx, config, pkg.XTestImportPos, XTestImport)
+
+	var ignore *Ignore
+	if config != nil {
+		ignore = config.Ignore
+	}
+	// the tree may be walked from a relative path, imports of the repo are resolved against the absolute one
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
 	}
+	ps.Packages = append(ps.Packages, &ProjectPackage{
+		Dir:         abs,
+		Name:        pkg.Name,
+		Imports:     importList(ignore, pkg.ImportPos),
+		TestImports: importList(ignore, pkg.TestImportPos, pkg.XTestImportPos),
+		HasTests:    len(pkg.TestGoFiles) > 0 || len(pkg.XTestGoFiles) > 0,
+	})
 	return nil
 }
 
+// The imports of the position maps, sorted and without the ignored ones.
+func importList(ignore *Ignore, positions ...map[string][]token.Position) []string {
+	found := make(map[string]bool)
+	for _, p := range positions {
+		for importPath := range p {
+			found[importPath] = !ignore.IgnoreImport(importPath)
+		}
+	}
+	imports := []string{}
+	for importPath, kept := range found {
+		if kept {
+			imports = append(imports, importPath)
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+func (ps *ProjectStats) foundImports(ctx *build.Context, config *Config, positions map[string][]token.Position, scope uint8) {
+	var ignore *Ignore
+	repo := ""
+	if config != nil {
+		ignore = config.Ignore
+		repo = config.Repository
+	}
+	for importPath, refs := range positions {
+		if ignore.IgnoreImport(importPath) {
+			continue
+		}
+		s, found := ps.ImportStatsByPath[importPath]
+		if !found {
+			s = NewImportStats(importPath, ImportOrigin(ctx, repo, importPath))
+			ps.ImportStatsByPath[importPath] = s
+		}
+		s.Scope |= scope
+		s.ReferencePositions = append(s.ReferencePositions, refs...)
+	}
+}
+
+// List the imported paths in lexical order.
+func (ps *ProjectStats) ImportPaths() []string {
+	paths := make([]string, 0, len(ps.ImportStatsByPath))
+	for path := range ps.ImportStatsByPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 func (ps *ProjectStats) IsImportUsed(importPath string) bool {
 	_, used := ps.ImportStatsByPath[importPath]
 	return used
@@ -138,7 +278,7 @@ func (ps *ProjectStats) PrintSummary() {
 	for _, item := range summary.Items {
 		fmt.Fprintln(writer, item.Legend())
 	}
-	fmt.Fprintln(writer, "\nR Remote, L Local, S Stdlib")
+	fmt.Fprintln(writer, "\nR Remote, P Project, L Local, S Stdlib")
 	writer.Flush()
 }
 
@@ -146,13 +286,9 @@ func (ps *ProjectStats) GetSummary() *Summary {
 	summary := &Summary{Items: []SummaryItem{}}
 
 	for k, v := range ps.ImportStatsByPath {
-		item := SummaryItem{Path: k, Sum: len(v.ReferencePositions)}
-		if v.Remote {
-			item.Origin = 1
-		} else if strings.HasPrefix(k, ".") {
-			item.Origin = 0
-		} else {
-			item.Origin = -1
+		item := SummaryItem{Origin: v.Origin, Path: k, Sum: len(v.ReferencePositions)}
+		if v.Symbols != nil {
+			item.Symbols = v.SymbolList(func(pos token.Position) bool { return true })
 		}
 		summary.Append(item)
 	}
@@ -161,15 +297,51 @@ func (ps *ProjectStats) GetSummary() *Summary {
 	return summary
 }
 
-func NewImportStats(importPath string, pos token.Position) *ImportStats {
-	parts := strings.Split(importPath, "/")
-	remote := false
-	if len(parts) > 0 && strings.Contains(parts[0], ".") && strings.Index(parts[0], ".") > 0 {
-		remote = true
+func NewImportStats(importPath string, origin int) *ImportStats {
+	return &ImportStats{Path: importPath, Origin: origin, Remote: origin == RemoteOrigin}
+}
+
+// Classify an import as a relative import, a package of the repository itself,
+// a package of the standard library in GOROOT, or a remote package.
+func ImportOrigin(ctx *build.Context, repo, importPath string) int {
+	if build.IsLocalImport(importPath) || strings.HasPrefix(importPath, "/") {
+		return LocalOrigin
+	}
+	if repo != "" && (importPath == repo || strings.HasPrefix(importPath, repo+"/")) {
+		return SelfOrigin
+	}
+	if isStdlib(ctx, importPath) {
+		return StdlibOrigin
+	}
+	return RemoteOrigin
+}
+
+func isStdlib(ctx *build.Context, importPath string) bool {
+	if importPath == "C" {
+		return true
 	}
-	return &ImportStats{
-		importPath, remote, []token.Position{pos},
+	if ctx.GOROOT == "" {
+		// no standard library to look at, guess from the path instead
+		return !strings.Contains(strings.Split(importPath, "/")[0], ".")
 	}
+	// GOROOT/src/pkg before Go 1.4
+	for _, src := range []string{"src", "src/pkg"} {
+		stat, err := os.Stat(filepath.Join(ctx.GOROOT, src, filepath.FromSlash(importPath)))
+		if err == nil && stat.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// Is the import referenced from non-test code.
+func (i *ImportStats) InProduction() bool {
+	return i.Scope&ProductionImport != 0
+}
+
+// Is the import referenced only from _test.go files.
+func (i *ImportStats) TestOnly() bool {
+	return i.Scope != 0 && !i.InProduction()
 }
 
 func (i *ImportStats) ReferenceList() string {
@@ -179,3 +351,27 @@ func (i *ImportStats) ReferenceList() string {
 	}
 	return fmt.Sprintf("* %s", strings.Join(lines, "\n* "))
 }
+
+// Build context for the analysis: GOOS, GOARCH and CGO_ENABLED come from
+// the environment as with the go tool and build tags from a -tags flag.
+func NewBuildContext(args []string) *build.Context {
+	ctx := build.Default
+	for i, arg := range args {
+		flag := strings.TrimLeft(arg, "-")
+		if flag == arg {
+			continue
+		}
+		if strings.HasPrefix(flag, "tags=") {
+			ctx.BuildTags = splitTags(strings.TrimPrefix(flag, "tags="))
+		} else if flag == "tags" && i+1 < len(args) {
+			ctx.BuildTags = splitTags(args[i+1])
+		}
+	}
+	return &ctx
+}
+
+func splitTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
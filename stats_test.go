@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"go/build"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -52,6 +54,80 @@ import "github.com/pelletier/go-toml"
 	}
 }
 
+func TestAnalyzeSourceTreeSkipsDirectoriesByDefault(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "fmt"
+`)
+
+	for _, dir := range []string{"testdata", "_old", ".hidden", "nested"} {
+		createSourceFixture(path.Join(pwd, dir), "foo.go", `package foo
+import "github.com/gorilla/mux"
+`)
+	}
+	createFixtureConfig(path.Join(pwd, "nested"), "")
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.IsImportUsed("github.com/gorilla/mux") {
+		t.Errorf("Expected to skip testdata, _, . prefixed and nested project directories\n")
+	}
+}
+
+func TestAnalyzeSourceTreeIgnore(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "fmt"
+import "appengine/datastore"
+`)
+
+	createSourceFixture(pwd, "foo.pb.go", `package main
+import "github.com/golang/protobuf/proto"
+`)
+
+	createSourceFixture(path.Join(pwd, "examples", "client"), "main.go", `package main
+import "github.com/gorilla/mux"
+`)
+
+	createSourceFixture(path.Join(pwd, "lib", "examples"), "main.go", `package examples
+import "github.com/bradfitz/gomemcache/memcache"
+`)
+
+	config := &Config{Ignore: &Ignore{
+		Paths:   []string{"examples/...", "*.pb.go"},
+		Imports: []string{"appengine/..."},
+	}}
+	stats, err := AnalyzeSourceTreeContext(pwd, &build.Default, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stats.IsImportUsed("fmt") {
+		t.Errorf("Expected fmt to be used\n")
+	}
+
+	if stats.IsImportUsed("github.com/gorilla/mux") {
+		t.Errorf("Expected to ignore the examples directory\n")
+	}
+
+	if !stats.IsImportUsed("github.com/bradfitz/gomemcache/memcache") {
+		t.Errorf("Expected to only ignore the examples directory at the root\n")
+	}
+
+	if stats.IsImportUsed("github.com/golang/protobuf/proto") {
+		t.Errorf("Expected to ignore generated files\n")
+	}
+
+	if stats.IsImportUsed("appengine/datastore") {
+		t.Errorf("Expected to ignore appengine imports\n")
+	}
+}
+
 func TestReferenceDifferentDependencies(t *testing.T) {
 	setupTestPwd()
 
@@ -187,6 +263,158 @@ import "github.com/pelletier/go-toml"
 	checkSumaryItem(t, s.Get(3), "fmt", "S	fmt	1")
 }
 
+func TestGetStatsSummaryWithRepository(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "fmt"
+import "net/http"
+import "./foo"
+import "corp/metrics"
+import "gopkg.in/yaml.v1"
+import "github.com/us/proj/sub"
+import "github.com/us/project"
+`)
+
+	config := &Config{Repository: "github.com/us/proj"}
+	stats, err := AnalyzeSourceTreeContext(pwd, &build.Default, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := stats.GetSummary()
+
+	checkSumaryItem(t, s.Get(0), "corp/metrics", "R	corp/metrics	1")
+	checkSumaryItem(t, s.Get(1), "github.com/us/project", "R	github.com/us/project	1")
+	checkSumaryItem(t, s.Get(2), "gopkg.in/yaml.v1", "R	gopkg.in/yaml.v1	1")
+	checkSumaryItem(t, s.Get(3), "github.com/us/proj/sub", "P	github.com/us/proj/sub	1")
+	checkSumaryItem(t, s.Get(4), "./foo", "L	./foo	1")
+	checkSumaryItem(t, s.Get(5), "fmt", "S	fmt	1")
+	checkSumaryItem(t, s.Get(6), "net/http", "S	net/http	1")
+
+	if stats.ImportStatsByPath["github.com/us/proj/sub"].Remote {
+		t.Errorf("Expected packages of the repository to not be remote\n")
+	}
+}
+
+func TestGetStatsSummaryBreaksTiesByPath(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "github.com/gorilla/mux"
+import "github.com/bradfitz/gomemcache/memcache"
+import "github.com/pelletier/go-toml"
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := stats.GetSummary()
+
+	checkSumaryItem(t, s.Get(0), "github.com/bradfitz/gomemcache/memcache", "R	github.com/bradfitz/gomemcache/memcache	1")
+	checkSumaryItem(t, s.Get(1), "github.com/gorilla/mux", "R	github.com/gorilla/mux	1")
+	checkSumaryItem(t, s.Get(2), "github.com/pelletier/go-toml", "R	github.com/pelletier/go-toml	1")
+}
+
+func TestAnalyzeSourceTreeHonorsBuildConstraints(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "fmt"
+`)
+
+	createSourceFixture(pwd, "foo_windows.go", `package main
+import "github.com/lxn/win"
+`)
+
+	createSourceFixture(pwd, "foo_integration.go", `// +build integration
+
+package main
+import "github.com/gorilla/mux"
+`)
+
+	ctx := build.Default
+	ctx.GOOS = "linux"
+	stats, err := AnalyzeSourceTreeContext(pwd, &ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.IsImportUsed("github.com/lxn/win") {
+		t.Errorf("Expected windows only imports to be skipped on linux\n")
+	}
+
+	if stats.IsImportUsed("github.com/gorilla/mux") {
+		t.Errorf("Expected tagged imports to be skipped without the tag\n")
+	}
+
+	ctx.GOOS = "windows"
+	ctx.BuildTags = []string{"integration"}
+	stats, err = AnalyzeSourceTreeContext(pwd, &ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stats.IsImportUsed("github.com/lxn/win") {
+		t.Errorf("Expected windows only imports to be used on windows\n")
+	}
+
+	if !stats.IsImportUsed("github.com/gorilla/mux") {
+		t.Errorf("Expected tagged imports to be used with the tag\n")
+	}
+}
+
+func TestAnalyzeSourceTreeImportScopes(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package foo
+import "fmt"
+`)
+
+	createSourceFixture(pwd, "foo_test.go", `package foo
+import "fmt"
+import "github.com/stretchr/testify/assert"
+`)
+
+	createSourceFixture(pwd, "example_test.go", `package foo_test
+import "github.com/stretchr/testify/mock"
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	istats := stats.ImportStatsByPath["fmt"]
+	if istats.Scope != ProductionImport|TestImport || !istats.InProduction() {
+		t.Errorf("Expected fmt to be imported from production and test code\n")
+	}
+
+	istats = stats.ImportStatsByPath["github.com/stretchr/testify/assert"]
+	if istats.Scope != TestImport || !istats.TestOnly() {
+		t.Errorf("Expected assert to be imported from test code only\n")
+	}
+
+	istats = stats.ImportStatsByPath["github.com/stretchr/testify/mock"]
+	if istats.Scope != XTestImport || !istats.TestOnly() {
+		t.Errorf("Expected mock to be imported from the external test package only\n")
+	}
+}
+
+func TestNewBuildContextTags(t *testing.T) {
+	ctx := NewBuildContext([]string{"test", "-tags", "integration,linux"})
+	if strings.Join(ctx.BuildTags, " ") != "integration linux" {
+		t.Errorf("Expected build tags to be parsed, but were %v\n", ctx.BuildTags)
+	}
+
+	ctx = NewBuildContext([]string{"build", "-tags=one two"})
+	if strings.Join(ctx.BuildTags, " ") != "one two" {
+		t.Errorf("Expected build tags to be parsed, but were %v\n", ctx.BuildTags)
+	}
+}
+
 func checkSumaryItem(t *testing.T, item SummaryItem, path, legend string) {
 	if item.Path != path {
 		t.Errorf("Expected item to be %s\n", path)
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// identifiers listed in the summary, the rest are only counted
+const maxListedSymbols = 5
+
+// The name and exported identifiers of an imported package.
+type importedPackage struct {
+	Name    string
+	Exports map[string]bool
+}
+
+// Find the exported identifiers of each import the project uses, through
+// selectors on the import's name or alias, or unqualified for dot imports.
+// The files parsed are the ones the imports were found in.
+func (ps *ProjectStats) AnalyzeSymbols(ctx *build.Context) error {
+	files := make(map[string]bool)
+	for _, s := range ps.ImportStatsByPath {
+		s.Symbols = make(map[string][]token.Position)
+		for _, ref := range s.ReferencePositions {
+			files[ref.Filename] = true
+		}
+	}
+	filenames := []string{}
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	packages := make(map[string]*importedPackage)
+	fset := token.NewFileSet()
+	for _, filename := range filenames {
+		f, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return err
+		}
+		ps.analyzeFileSymbols(ctx, fset, f, filepath.Dir(filename), packages)
+	}
+	return nil
+}
+
+func (ps *ProjectStats) analyzeFileSymbols(ctx *build.Context, fset *token.FileSet, f *ast.File, dir string, packages map[string]*importedPackage) {
+	// import path of each name qualifying identifiers in the file
+	qualifiers := make(map[string]string)
+	dotImports := []string{}
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || ps.ImportStatsByPath[importPath] == nil {
+			continue
+		}
+		switch {
+		case spec.Name == nil:
+			qualifiers[ps.loadImportedPackage(ctx, importPath, dir, packages).Name] = importPath
+		case spec.Name.Name == ".":
+			dotImports = append(dotImports, importPath)
+		case spec.Name.Name != "_":
+			qualifiers[spec.Name.Name] = importPath
+		}
+	}
+
+	record := func(importPath string, ident *ast.Ident) {
+		if ast.IsExported(ident.Name) {
+			s := ps.ImportStatsByPath[importPath]
+			s.Symbols[ident.Name] = append(s.Symbols[ident.Name], fset.Position(ident.Pos()))
+		}
+	}
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.SelectorExpr:
+			// identifiers resolved in the file are local variables shadowing the import
+			if id, ok := x.X.(*ast.Ident); ok && id.Obj == nil {
+				if importPath, found := qualifiers[id.Name]; found {
+					record(importPath, x.Sel)
+					return false
+				}
+			}
+			// the selected field or method isn't from a dot import
+			ast.Inspect(x.X, visit)
+			return false
+		case *ast.Ident:
+			if x.Obj != nil {
+				return false
+			}
+			for _, importPath := range dotImports {
+				if ps.loadImportedPackage(ctx, importPath, dir, packages).Exports[x.Name] {
+					record(importPath, x)
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(f, visit)
+}
+
+// The imported package as found in the vendor tree or the build context, its name
+// is guessed from the import path and its exports are unknown when it can't be found.
+func (ps *ProjectStats) loadImportedPackage(ctx *build.Context, importPath, dir string, packages map[string]*importedPackage) *importedPackage {
+	if p, found := packages[importPath]; found {
+		return p
+	}
+
+	p := &importedPackage{Name: guessPackageName(importPath), Exports: make(map[string]bool)}
+	var pkg *build.Package
+	var err error
+	if ps.ImportStatsByPath[importPath].Remote {
+		pkg, err = ctx.ImportDir(NewDependency(importPath).Src(), 0)
+	} else {
+		pkg, err = ctx.Import(importPath, dir, 0)
+	}
+	if err == nil {
+		p.Name = pkg.Name
+		fset := token.NewFileSet()
+		for _, name := range append(pkg.GoFiles, pkg.CgoFiles...) {
+			f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, 0)
+			if err != nil {
+				continue
+			}
+			for name, obj := range f.Scope.Objects {
+				if ast.IsExported(name) && obj.Kind != ast.Bad {
+					p.Exports[name] = true
+				}
+			}
+		}
+	}
+	packages[importPath] = p
+	return p
+}
+
+// The package name usually declared by the package at importPath:
+// github.com/pelletier/go-toml is toml and gopkg.in/yaml.v2 is yaml.
+func guessPackageName(importPath string) string {
+	name := path.Base(majorSuffix.ReplaceAllString(importPath, ""))
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "go-"), "-go")
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// The distinct identifiers of the import used in the positions accepted by keep, sorted.
+func (i *ImportStats) SymbolList(keep func(pos token.Position) bool) []string {
+	symbols := []string{}
+	for symbol, positions := range i.Symbols {
+		for _, pos := range positions {
+			if keep(pos) {
+				symbols = append(symbols, symbol)
+				break
+			}
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// The imports of each package directory of the project, relative to root.
+type PackageSummary struct {
+	Dir     string
+	Summary *Summary
+}
+
+func (ps *ProjectStats) GetPackageSummaries(root string) []*PackageSummary {
+	byDir := make(map[string]*Summary)
+	for importPath, s := range ps.ImportStatsByPath {
+		refs := make(map[string]int)
+		for _, ref := range s.ReferencePositions {
+			refs[relativePath(root, filepath.Dir(ref.Filename))]++
+		}
+		for dir, sum := range refs {
+			item := SummaryItem{Origin: s.Origin, Path: importPath, Sum: sum}
+			if s.Symbols != nil {
+				item.Symbols = s.SymbolList(func(pos token.Position) bool {
+					return relativePath(root, filepath.Dir(pos.Filename)) == dir
+				})
+			}
+			if byDir[dir] == nil {
+				byDir[dir] = &Summary{Items: []SummaryItem{}}
+			}
+			byDir[dir].Append(item)
+		}
+	}
+
+	summaries := []*PackageSummary{}
+	for dir, summary := range byDir {
+		sort.Sort(summary)
+		summaries = append(summaries, &PackageSummary{dir, summary})
+	}
+	sort.Sort(packageSummaries(summaries))
+	return summaries
+}
+
+type packageSummaries []*PackageSummary
+
+func (p packageSummaries) Len() int           { return len(p) }
+func (p packageSummaries) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p packageSummaries) Less(i, j int) bool { return p[i].Dir < p[j].Dir }
+
+func (ps *ProjectStats) PrintPackageSummaries(root string) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintln(writer, "Import stats by package:")
+	for _, p := range ps.GetPackageSummaries(root) {
+		fmt.Fprintf(writer, "\n%s\n", p.Dir)
+		for _, item := range p.Summary.Items {
+			fmt.Fprintln(writer, item.Legend())
+		}
+	}
+	fmt.Fprintln(writer, "\nR Remote, P Project, L Local, S Stdlib")
+	writer.Flush()
+}
+
+// gp stats [--packages] [--symbols]
+func printStats(config *Config, p *ProjectStats, args []string) {
+	if hasFlag(args, "--symbols") {
+		if err := p.AnalyzeSymbols(NewBuildContext(args)); err != nil {
+			fail(err)
+		}
+	}
+	if hasFlag(args, "--packages") {
+		p.PrintPackageSummaries(config.Dir)
+	} else {
+		p.PrintSummary()
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go/build"
+	"go/token"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeSymbols(t *testing.T) {
+	setupTestPwd()
+
+	widgets := NewDependency("github.com/acme/go-widgets")
+	createSourceFixture(widgets.Src(), "widgets.go", `package widgets
+func New() {}
+func Render() {}
+`)
+
+	createSourceFixture(pwd, "main.go", `package main
+
+import (
+	"strings"
+	str "strconv"
+	. "unicode"
+	_ "net/http/pprof"
+	"github.com/acme/go-widgets"
+)
+
+func main() {
+	widgets.New()
+	_ = strings.ToUpper(str.Itoa(1))
+	_ = strings.Split(strings.ToUpper("a"), "")
+	_ = IsUpper('A')
+}
+`)
+	createSourceFixture(pwd, "other.go", `package main
+
+import "strings"
+
+func other() {
+	strings := builder{}
+	strings.Reset()
+}
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.AnalyzeSymbols(&build.Default); err != nil {
+		t.Fatal(err)
+	}
+
+	all := func(pos token.Position) bool { return true }
+	for importPath, expected := range map[string]string{
+		"strings":                    "Split ToUpper",
+		"strconv":                    "Itoa",
+		"unicode":                    "IsUpper",
+		"net/http/pprof":             "",
+		"github.com/acme/go-widgets": "New",
+	} {
+		symbols := strings.Join(stats.ImportStatsByPath[importPath].SymbolList(all), " ")
+		if symbols != expected {
+			t.Errorf("Expected %s to be used for %q, but was %q", importPath, expected, symbols)
+		}
+	}
+
+	if refs := stats.ImportStatsByPath["strings"].Symbols["ToUpper"]; len(refs) != 2 {
+		t.Errorf("Expected strings.ToUpper to be referenced twice, but was %v", refs)
+	}
+
+	item := stats.GetSummary().Items[0]
+	if legend := item.Legend(); legend != "R\tgithub.com/acme/go-widgets\t1\t1\tNew" {
+		t.Errorf("Expected the legend to list the symbols used, but was %q", legend)
+	}
+}
+
+func TestGetPackageSummaries(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(pwd, "main.go", `package main
+import "github.com/acme/log"
+import "fmt"
+`)
+	createSourceFixture(path.Join(pwd, "api"), "api.go", `package api
+import "github.com/acme/log"
+import "github.com/gorilla/mux"
+`)
+	createSourceFixture(path.Join(pwd, "api"), "api_test.go", `package api
+import "github.com/gorilla/mux"
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages := []string{}
+	for _, p := range stats.GetPackageSummaries(pwd) {
+		legends := []string{}
+		for _, item := range p.Summary.Items {
+			legends = append(legends, item.Legend())
+		}
+		packages = append(packages, p.Dir+": "+strings.Join(legends, ", "))
+	}
+	expected := []string{
+		".: R\tgithub.com/acme/log\t1, S\tfmt\t1",
+		"api: R\tgithub.com/gorilla/mux\t2, R\tgithub.com/acme/log\t1",
+	}
+	if strings.Join(packages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected package summaries:\n%s\nbut were:\n%s", strings.Join(expected, "\n"), strings.Join(packages, "\n"))
+	}
+}
+
+func TestGuessPackageName(t *testing.T) {
+	for importPath, name := range map[string]string{
+		"github.com/pelletier/go-toml": "toml",
+		"gopkg.in/yaml.v2":             "yaml",
+		"github.com/go-chi/chi/v5":     "chi",
+		"github.com/mattn/go-sqlite3":  "sqlite3",
+		"github.com/acme/log-go":       "log",
+		"github.com/acme/time-series":  "time_series",
+	} {
+		if guessed := guessPackageName(importPath); guessed != name {
+			t.Errorf("Expected the package name of %s to be %s, but was %s", importPath, name, guessed)
+		}
+	}
+}
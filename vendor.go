@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultVendorOut = "vendor"
+	VendorManifest   = "gopack.manifest"
+)
+
+// The packages of the dependencies the project needs: the remote packages it imports
+// and, transitively, the packages of the dependencies these import.
+// Imports are read from the vendor tree with the build constraints of ctx.
+func ImportedPackages(ctx *build.Context, p *ProjectStats, deps []*Dep) map[string]bool {
+	packages := make(map[string]bool)
+	pending := []string{}
+	for _, path := range p.ImportPaths() {
+		if p.ImportStatsByPath[path].Remote {
+			pending = append(pending, path)
+		}
+	}
+
+	for len(pending) > 0 {
+		path := pending[0]
+		pending = pending[1:]
+		if packages[path] || providingDependency(deps, path) == nil {
+			continue
+		}
+		packages[path] = true
+
+		pkg, err := ctx.ImportDir(NewDependency(path).Src(), 0)
+		if err != nil {
+			continue
+		}
+		pending = append(pending, pkg.Imports...)
+	}
+
+	return packages
+}
+
+func providingDependency(deps []*Dep, importPath string) *Dep {
+	for _, dep := range deps {
+		if importPath == dep.Import || strings.HasPrefix(importPath, dep.Import+"/") {
+			return dep
+		}
+	}
+	return nil
+}
+
+// Copy the sources of the dependency from the vendor tree to out/<import>,
+// leaving the scm metadata behind. When packages isn't nil only the directories
+// of those packages are copied, along with the files at the root of the
+// dependency that aren't Go sources, like its license.
+func CopyDependency(dep *Dep, out string, packages map[string]bool) error {
+	// local checkouts are linked into the vendor tree, and Walk doesn't follow links
+	src, err := filepath.EvalSymlinks(dep.Src())
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(out, filepath.FromSlash(dep.Import))
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			for _, scm := range scmDirs {
+				if info.Name() == scm {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		if packages != nil {
+			importPath := dep.Import
+			if dir != src {
+				importPath = dep.Import + "/" + relativePath(src, dir)
+			}
+			root := dir == src && !strings.HasSuffix(info.Name(), ".go")
+			if !packages[importPath] && !root {
+				return nil
+			}
+		}
+
+		target := filepath.Join(dst, relativePath(src, path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Write the manifest of the exported dependencies, a table per dependency
+// with its checkout spec and the revision returned by revision.
+func WriteManifest(w io.Writer, deps []*Dep, revision func(dep *Dep) (string, error)) error {
+	sorted := make([]*Dep, len(deps))
+	copy(sorted, deps)
+	sort.Sort(depsByImport(sorted))
+
+	fmt.Fprintf(w, "# generated by gp vendor, gopack %s\n", GopackVersion)
+	keys := make(map[string]bool)
+	for _, dep := range sorted {
+		rev, err := revision(dep)
+		if err != nil {
+			return fmt.Errorf("%s: couldn't read revision: %s", dep.Import, err)
+		}
+
+		key := tableKey(dep.Import, keys)
+		keys[key] = true
+		fmt.Fprintf(w, "\n[deps.%s]\n", key)
+		fmt.Fprintf(w, "import = %q\n", dep.Import)
+		if dep.CheckoutType() != "" {
+			fmt.Fprintf(w, "%s = %q\n", dep.CheckoutType(), dep.CheckoutSpec)
+		}
+		fmt.Fprintf(w, "revision = %q\n", rev)
+	}
+	return nil
+}
+
+// Remove the dependencies of the manifest in out that aren't in deps anymore,
+// along with the directories they leave empty.
+func RemoveStaleDependencies(out string, deps []*Dep) error {
+	t, err := toml.LoadFile(filepath.Join(out, VendorManifest))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	depsTree, err := getTable(t, "deps")
+	if err != nil || depsTree == nil {
+		return err
+	}
+
+	current := make(map[string]bool)
+	for _, dep := range deps {
+		current[dep.Import] = true
+	}
+	for _, key := range depsTree.Keys() {
+		depTree, err := getTable(depsTree, key)
+		if err != nil {
+			return err
+		}
+		importPath, err := getString(depTree, ImportProp)
+		if err != nil || importPath == "" || current[importPath] {
+			continue
+		}
+		fmtcolor(Gray, "removing %s\n", importPath)
+		dir := filepath.Join(out, filepath.FromSlash(importPath))
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		for parent := filepath.Dir(dir); parent != filepath.Clean(out); parent = filepath.Dir(parent) {
+			// only empty directories can be removed
+			if os.Remove(parent) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func scmRevision(dep *Dep) (string, error) {
+	scm, err := dep.Scm()
+	if err != nil {
+		return "", err
+	}
+	return scm.Revision(dep)
+}
+
+// gp vendor [--out dir] [--prune]
+func vendorDependencies(config *Config, p *ProjectStats, args []string) {
+	out := flagValue(args, "--out", DefaultVendorOut)
+
+	deps := resolveDependencies(config, p)
+	if deps == nil {
+		fmt.Println("no dependencies to vendor")
+		return
+	}
+
+	var packages map[string]bool
+	if hasFlag(args, "--prune") {
+		packages = ImportedPackages(NewBuildContext(args), p, deps)
+	}
+
+	if err := RemoveStaleDependencies(out, deps); err != nil {
+		fail(err)
+	}
+	for _, dep := range deps {
+		fmtcolor(Gray, "copying %s\n", dep.Import)
+		if err := CopyDependency(dep, out, packages); err != nil {
+			fail(err)
+		}
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		fail(err)
+	}
+	var manifest bytes.Buffer
+	if err := WriteManifest(&manifest, deps, scmRevision); err != nil {
+		fail(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(out, VendorManifest), manifest.Bytes(), 0644); err != nil {
+		fail(err)
+	}
+	fmtcolor(Green, "vendored %d dependencies in %s\n", len(deps), out)
+}
+
+// The value of a --name value or --name=value flag, def when it isn't set.
+func flagValue(args []string, name, def string) string {
+	for i, arg := range args {
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"=")
+		}
+	}
+	return def
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"os"
+	"path"
+	"testing"
+)
+
+func setupVendoredDependency() *Dep {
+	setupTestPwd()
+
+	dep := createScmDep(".git", "github.com/gorilla/mux", "objects")
+	createSourceFixture(dep.Src(), "LICENSE", "BSD")
+	createSourceFixture(dep.Src(), "mux.go", `package mux
+import "github.com/gorilla/mux/route"
+`)
+	createSourceFixture(path.Join(dep.Src(), "route"), "route.go", "package route\n")
+	createSourceFixture(path.Join(dep.Src(), "examples"), "main.go", "package main\n")
+
+	createSourceFixture(pwd, "main.go", `package main
+import "github.com/gorilla/mux"
+`)
+	return dep
+}
+
+func TestCopyDependency(t *testing.T) {
+	dep := setupVendoredDependency()
+	out := path.Join(pwd, "vendor")
+
+	err := CopyDependency(dep, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"LICENSE", "mux.go", "route/route.go", "examples/main.go"} {
+		if _, err := os.Stat(path.Join(out, dep.Import, file)); err != nil {
+			t.Errorf("Expected %s to be copied", file)
+		}
+	}
+	if _, err := os.Stat(path.Join(out, dep.Import, ".git")); err == nil {
+		t.Errorf("Expected the scm metadata to be left behind")
+	}
+}
+
+func TestCopyPrunedDependency(t *testing.T) {
+	dep := setupVendoredDependency()
+	out := path.Join(pwd, "vendor")
+
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages := ImportedPackages(&build.Default, p, []*Dep{dep})
+	if len(packages) != 2 || !packages["github.com/gorilla/mux/route"] {
+		t.Errorf("Expected the imported packages and their imports, but were %v", packages)
+	}
+
+	err = CopyDependency(dep, out, packages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"LICENSE", "mux.go", "route/route.go"} {
+		if _, err := os.Stat(path.Join(out, dep.Import, file)); err != nil {
+			t.Errorf("Expected %s to be copied", file)
+		}
+	}
+	if _, err := os.Stat(path.Join(out, dep.Import, "examples")); err == nil {
+		t.Errorf("Expected packages that aren't imported to be pruned")
+	}
+}
+
+func TestCopyLinkedDependency(t *testing.T) {
+	setupTestPwd()
+	checkout := path.Join(pwd, "checkouts", "mux")
+	createSourceFixture(checkout, "mux.go", "package mux\n")
+	dep := NewDependency("github.com/gorilla/mux")
+	os.MkdirAll(path.Dir(dep.Src()), 0755)
+	if err := os.Symlink(checkout, dep.Src()); err != nil {
+		t.Fatal(err)
+	}
+
+	out := path.Join(pwd, "vendor")
+	if err := CopyDependency(dep, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(path.Join(out, dep.Import, "mux.go"))
+	if err != nil || !info.Mode().IsRegular() {
+		t.Errorf("Expected the sources of the local checkout to be copied")
+	}
+}
+
+func TestRemoveStaleDependencies(t *testing.T) {
+	setupTestPwd()
+	out := path.Join(pwd, "vendor")
+	createSourceFixture(path.Join(out, "github.com/gorilla/mux"), "mux.go", "package mux\n")
+	createSourceFixture(path.Join(out, "github.com/gorilla/context"), "context.go", "package context\n")
+	createSourceFixture(path.Join(out, "github.com/old/lib"), "lib.go", "package lib\n")
+	createSourceFixture(out, VendorManifest, `
+[deps.mux]
+import = "github.com/gorilla/mux"
+revision = "a1"
+
+[deps.context]
+import = "github.com/gorilla/context"
+revision = "b1"
+
+[deps.lib]
+import = "github.com/old/lib"
+revision = "c1"
+`)
+
+	if err := RemoveStaleDependencies(out, []*Dep{NewDependency("github.com/gorilla/mux")}); err != nil {
+		t.Fatal(err)
+	}
+	for dir, kept := range map[string]bool{
+		"github.com/gorilla/mux":     true,
+		"github.com/gorilla/context": false,
+		"github.com/old":             false,
+	} {
+		if _, err := os.Stat(path.Join(out, dir)); (err == nil) != kept {
+			t.Errorf("Expected %s to be kept: %v", dir, kept)
+		}
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	deps := []*Dep{
+		{Import: "github.com/pelletier/go-toml", CheckoutFlag: CommitFlag, CheckoutSpec: "23d36c08ab90f4957ae8e7d781907c368f5454dd"},
+		{Import: "github.com/gorilla/mux", CheckoutFlag: TagFlag, CheckoutSpec: "1.0rc2"},
+	}
+	revision := func(dep *Dep) (string, error) {
+		if dep.CheckoutFlag == CommitFlag {
+			return dep.CheckoutSpec, nil
+		}
+		return "9b36453141c35697401168b07f2c09fcff7721ec", nil
+	}
+
+	var w bytes.Buffer
+	err := WriteManifest(&w, deps, revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# generated by gp vendor, gopack ` + GopackVersion + `
+
+[deps.mux]
+import = "github.com/gorilla/mux"
+tag = "1.0rc2"
+revision = "9b36453141c35697401168b07f2c09fcff7721ec"
+
+[deps.go-toml]
+import = "github.com/pelletier/go-toml"
+commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+revision = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+`
+	if w.String() != expected {
+		t.Errorf("Expected manifest:\n%s\nbut was:\n%s", expected, w.String())
+	}
+}
+
+func TestAnalyzeSourceTreeSkipsVendoredTree(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(path.Join(pwd, "vendor"), VendorManifest, "")
+	createSourceFixture(path.Join(pwd, "vendor", "github.com", "gorilla", "mux"), "mux.go", `package mux
+import "github.com/gorilla/context"
+`)
+
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ImportStatsByPath) != 0 {
+		t.Errorf("Expected the vendored tree to be skipped, but found %v", p.ImportPaths())
+	}
+}
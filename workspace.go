@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	GopackWorkspace = "gopack.workspace"
+	MembersProp     = "members"
+)
+
+// Several projects sharing one vendor tree, declared in a gopack.workspace file
+// at the root of the repository:
+//
+//	members = ["services/api", "services/worker"]
+type Workspace struct {
+	// Directory holding gopack.workspace and the shared vendor tree.
+	Root string
+	// Member project directories, relative to the root.
+	Members []string
+}
+
+// Find the closest directory to dir with a gopack.workspace file.
+func FindWorkspace(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, GopackWorkspace)); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found", GopackWorkspace)
+		}
+		dir = parent
+	}
+}
+
+func NewWorkspace(root string) *Workspace {
+	t, err := toml.LoadFile(filepath.Join(root, GopackWorkspace))
+	if err != nil {
+		fail(err)
+	}
+
+	members, err := getStrings(t, MembersProp)
+	if err != nil {
+		failf("%s - %s\n", filepath.Join(root, GopackWorkspace), err)
+	}
+	w := &Workspace{Root: root, Members: members}
+	if len(w.Members) == 0 {
+		failf("%s - no members\n", filepath.Join(root, GopackWorkspace))
+	}
+	return w
+}
+
+func (w *Workspace) MemberDir(member string) string {
+	return filepath.Join(w.Root, member)
+}
+
+// Load the config of every member, with the member directory as project directory.
+func (w *Workspace) Configs() []*Config {
+	configs := []*Config{}
+	for _, member := range w.Members {
+		config := NewConfig(w.MemberDir(member))
+		config.Dir = w.MemberDir(member)
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+func (w *Workspace) Repositories(configs []*Config) []string {
+	repos := []string{}
+	for _, config := range configs {
+		if config.Repository != "" {
+			repos = append(repos, config.Repository)
+		}
+	}
+	return repos
+}
+
+// The policies of the members, the shared dependencies must follow all of them.
+func (w *Workspace) Policies(configs []*Config) []*Policy {
+	policies := []*Policy{}
+	for _, config := range configs {
+		policies = append(policies, config.Policy)
+	}
+	return policies
+}
+
+func (w *Workspace) Severities(configs []*Config) Severities {
+	all := []Severities{}
+	for _, config := range configs {
+		all = append(all, config.Severities)
+	}
+	return StrictestSeverities(all...)
+}
+
+// Resolve the dependencies of all the members into a single model, with one
+// checkout per import. Imports that members pin differently are reported as conflicts.
+// The model is nil when no member needs to fetch its dependencies or requires immutable ones.
+func (w *Workspace) LoadDependencyModel(configs []*Config, importGraph *Graph) (*Dependencies, []*ProjectError) {
+	deps := &Dependencies{
+		Imports:     []string{},
+		Keys:        []string{},
+		DepList:     []*Dep{},
+		ImportGraph: importGraph,
+	}
+	// members requiring each checkout of each import
+	requiredBy := make(map[string]map[string][]string)
+	byImport := make(map[string]*Dep)
+	fetchDeps := false
+
+	for i, config := range configs {
+		config.InitRepo(importGraph)
+		memberDeps, fetch := config.DependencyModel(importGraph)
+		if memberDeps == nil {
+			continue
+		}
+		// require-immutable checks the configs of the dependencies and the lock even when nothing needs fetching
+		fetchDeps = fetchDeps || fetch || config.Policy.RequireImmutable
+
+		for j, dep := range memberDeps.DepList {
+			checkout := dep.CheckoutType() + " " + dep.CheckoutSpec
+			if _, found := requiredBy[dep.Import]; !found {
+				requiredBy[dep.Import] = make(map[string][]string)
+			}
+			requiredBy[dep.Import][checkout] = append(requiredBy[dep.Import][checkout], w.Members[i])
+
+			existing, found := byImport[dep.Import]
+			if !found {
+				byImport[dep.Import] = dep
+				deps.Keys = append(deps.Keys, memberDeps.Keys[j])
+				deps.Imports = append(deps.Imports, dep.Import)
+				deps.DepList = append(deps.DepList, dep)
+				continue
+			}
+			existing.fetch = existing.fetch || dep.fetch
+			existing.skip = existing.skip && dep.skip
+		}
+	}
+
+	conflicts := []*ProjectError{}
+	imports := []string{}
+	for importPath, checkouts := range requiredBy {
+		if len(checkouts) > 1 {
+			imports = append(imports, importPath)
+		}
+	}
+	sort.Strings(imports)
+	for _, importPath := range imports {
+		conflicts = append(conflicts, DependencyConflictError(importPath, requiredBy[importPath]))
+	}
+
+	if !fetchDeps {
+		deps = nil
+	}
+	return deps, conflicts
+}
+
+// gp -w <command>
+func runWorkspace(w *Workspace, args []string) {
+	first := args[0]
+	switch first {
+	case "config", "import", "fix", "vendor", "export", "licenses", "audit":
+		// they would be run as go commands in each member
+		failf("gp %s doesn't support -w, run it in each member instead\n", first)
+	}
+	configs := w.Configs()
+	stats := []*ProjectStats{}
+	memberDeps := []*Dependencies{}
+
+	for _, config := range configs {
+		config.IncludeTests = first == "test"
+		if strictValidation {
+			config.Severities = Severities{}
+		}
+		if requireImmutable {
+			config.Policy.RequireImmutable = true
+		}
+
+		p, err := AnalyzeSourceTreeContext(config.Dir, NewBuildContext(args), config)
+		if err != nil {
+			fail(err)
+		}
+		stats = append(stats, p)
+
+		importGraph := NewGraph()
+		config.InitRepo(importGraph)
+		deps, _ := config.DependencyModel(importGraph)
+		if deps == nil {
+			deps = &Dependencies{ImportGraph: importGraph}
+		}
+		memberDeps = append(memberDeps, deps)
+	}
+
+	// problems of the shared dependencies are as severe as the strictest member makes them
+	severities := w.Severities(configs)
+	importGraph := NewGraph()
+	deps, conflicts := w.LoadDependencyModel(configs, importGraph)
+	validateWithSeverities(severities, conflicts)
+
+	if deps != nil {
+		announceGopack()
+		for i, config := range configs {
+			validateWith(config, memberDeps[i].Validate(stats[i]))
+		}
+		// members share the lock along with the vendor tree
+		lock := loadLock(filepath.Join(w.Root, GopackLock))
+		resolver := loadTransitiveDependencies(deps, lock, w.Policies(configs), w.Repositories(configs)...)
+		validateWithSeverities(severities, resolver.Errors)
+		validateWithSeverities(severities, lock.Update(resolver.Deps, resolver.Skipped, scmRevision))
+		for i, config := range configs {
+			validateWith(config, memberDeps[i].ValidatePackages(stats[i]))
+			validateWith(config, validateReachability(config, memberDeps[i], stats[i]))
+			validateWith(config, validateLicenses(config, resolver.Deps))
+			config.WriteChecksum()
+		}
+		writeLock(lock)
+	}
+
+	switch first {
+	case "dependencytree":
+		(&Dependencies{ImportGraph: importGraph}).PrintDependencyTree()
+	case "stats":
+		for i, member := range w.Members {
+			fmtcolor(Blue, "%s\n", member)
+			statsCommand(configs[i], stats[i], args[1:])
+		}
+	default:
+		runWorkspaceCommand(w, args)
+	}
+}
+
+// Run the go command in every member directory, exiting with the number of failures.
+func runWorkspaceCommand(w *Workspace, args []string) {
+	failed := []string{}
+	for _, member := range w.Members {
+		fmtcolor(Blue, "%s: go %s\n", member, strings.Join(args, " "))
+		cmd := exec.Command("go", args...)
+		cmd.Dir = w.MemberDir(member)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			failed = append(failed, member)
+		}
+	}
+
+	if len(failed) > 0 {
+		failf("failed in %s\n", strings.Join(failed, ", "))
+	}
+}
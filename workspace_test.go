@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func setupWorkspace(members map[string]string) *Workspace {
+	setupTestPwd()
+	setupEnv()
+
+	names := []string{}
+	for member, config := range members {
+		createPath(path.Join(pwd, member))
+		createFixtureConfig(path.Join(pwd, member), config)
+		names = append(names, member)
+	}
+	sortedStrings(names)
+
+	quoted := []string{}
+	for _, member := range names {
+		quoted = append(quoted, `"`+member+`"`)
+	}
+	err := ioutil.WriteFile(path.Join(pwd, GopackWorkspace), []byte("members = ["+strings.Join(quoted, ", ")+"]\n"), 0644)
+	check(err)
+
+	return NewWorkspace(pwd)
+}
+
+func TestFindWorkspace(t *testing.T) {
+	setupWorkspace(map[string]string{"services/api": ""})
+
+	root, err := FindWorkspace(path.Join(pwd, "services", "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != pwd {
+		t.Errorf("Expected the workspace root to be %s, but was %s", pwd, root)
+	}
+
+	w := NewWorkspace(root)
+	if len(w.Members) != 1 || w.MemberDir(w.Members[0]) != path.Join(pwd, "services", "api") {
+		t.Errorf("Expected services/api to be the only member, but were %v", w.Members)
+	}
+}
+
+func TestWorkspaceDependencyModel(t *testing.T) {
+	w := setupWorkspace(map[string]string{
+		"api": `
+repo = "github.com/acme/api"
+
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "1.0rc2"
+`,
+		"worker": `
+repo = "github.com/acme/worker"
+
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "1.0rc2"
+
+[deps.toml]
+  import = "github.com/pelletier/go-toml"
+  commit = "23d36c08ab90f4957ae8e7d781907c368f5454dd"
+`,
+	})
+
+	configs := w.Configs()
+	if configs[0].Dir != path.Join(pwd, "api") {
+		t.Errorf("Expected members to be linked from their own directory, but was %s", configs[0].Dir)
+	}
+
+	repos := w.Repositories(configs)
+	if len(repos) != 2 || repos[0] != "github.com/acme/api" || repos[1] != "github.com/acme/worker" {
+		t.Errorf("Expected the repositories of all the members, but were %v", repos)
+	}
+
+	deps, conflicts := w.LoadDependencyModel(configs, NewGraph())
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, but were %v", conflicts)
+	}
+	if deps == nil || len(deps.DepList) != 2 {
+		t.Fatalf("Expected one dependency per import, but were %v", deps)
+	}
+}
+
+func TestWorkspaceDependencyConflict(t *testing.T) {
+	w := setupWorkspace(map[string]string{
+		"api": `
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "1.0rc2"
+`,
+		"worker": `
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  branch = "master"
+`,
+	})
+
+	_, conflicts := w.LoadDependencyModel(w.Configs(), NewGraph())
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, but were %d", len(conflicts))
+	}
+
+	e := conflicts[0]
+	if e.Kind != DependencyConflict || e.Path != "github.com/gorilla/mux" {
+		t.Errorf("Expected a conflict on mux, but was %s on %s", e.Kind, e.Path)
+	}
+	expected := "github.com/gorilla/mux is required at different versions by workspace members\n" +
+		"  branch master in worker\n" +
+		"  tag 1.0rc2 in api\n"
+	if e.Message != expected {
+		t.Errorf("Expected message %q, but was %q", expected, e.Message)
+	}
+}